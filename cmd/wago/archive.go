@@ -0,0 +1,96 @@
+package wago
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/model"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+// archiveSetter archives or restores one kind of item in the store
+type archiveSetter func(s storage.Store, name string, archived bool) error
+
+// newArchiveCmds builds the archive and unarchive subcommands for a kind
+// of item (wallet, contact or category)
+func newArchiveCmds(kind string, set archiveSetter) (*cobra.Command, *cobra.Command) {
+	run := func(archived bool) func(cmd *cobra.Command, args []string) {
+		return func(cmd *cobra.Command, args []string) {
+			s, err := storage.New()
+			if err != nil {
+				er(fmt.Sprintf("Failed to initialize storage: %v", err))
+				return
+			}
+			defer s.Close()
+
+			name := args[0]
+			action := "archive"
+			if !archived {
+				action = "unarchive"
+			}
+			if err := set(s, name, archived); err != nil {
+				er(fmt.Sprintf("Failed to %s %s: %v", action, kind, err))
+				return
+			}
+
+			fmt.Printf("%s '%s' %sd successfully\n", strings.Title(kind), name, action)
+		}
+	}
+
+	archiveCmd := &cobra.Command{
+		Use:   "archive [name]",
+		Short: fmt.Sprintf("Archive a %s", kind),
+		Long:  fmt.Sprintf("Hide a %s from listings and the dashboard while keeping its history.", kind),
+		Args:  cobra.ExactArgs(1),
+		Run:   run(true),
+	}
+	unarchiveCmd := &cobra.Command{
+		Use:   "unarchive [name]",
+		Short: fmt.Sprintf("Restore an archived %s", kind),
+		Long:  fmt.Sprintf("Restore an archived %s so it shows up again.", kind),
+		Args:  cobra.ExactArgs(1),
+		Run:   run(false),
+	}
+	return archiveCmd, unarchiveCmd
+}
+
+// activeWallets drops archived wallets and the wallets of archived categories
+func activeWallets(wallets []*model.Wallet, categories []*model.Category) []*model.Wallet {
+	archivedCategories := make(map[string]bool)
+	for _, category := range categories {
+		if category.Archived {
+			archivedCategories[category.Name] = true
+		}
+	}
+
+	active := make([]*model.Wallet, 0, len(wallets))
+	for _, wallet := range wallets {
+		if !wallet.Archived && !archivedCategories[wallet.Category] {
+			active = append(active, wallet)
+		}
+	}
+	return active
+}
+
+// activeCategories drops archived categories
+func activeCategories(categories []*model.Category) []*model.Category {
+	active := make([]*model.Category, 0, len(categories))
+	for _, category := range categories {
+		if !category.Archived {
+			active = append(active, category)
+		}
+	}
+	return active
+}
+
+// activeContacts drops archived contacts
+func activeContacts(contacts []*model.Contact) []*model.Contact {
+	active := make([]*model.Contact, 0, len(contacts))
+	for _, contact := range contacts {
+		if !contact.Archived {
+			active = append(active, contact)
+		}
+	}
+	return active
+}
@@ -0,0 +1,47 @@
+package wago
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+func init() {
+	// Migrate backend command
+	migrateBackendCmd := &cobra.Command{
+		Use:   "migrate-backend [json|sqlite]",
+		Short: "Move the ledger to another storage backend",
+		Long: `Copy the ledger to another storage backend and switch to it.
+The json backend rewrites wago.json on every change; the sqlite backend stores
+each record as a row in wago.db and only writes what changed, which keeps large
+ledgers fast. Without an argument the current backend is shown.`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  migrateBackend,
+	}
+
+	rootCmd.AddCommand(migrateBackendCmd)
+}
+
+func migrateBackend(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	if len(args) == 0 {
+		fmt.Printf("Current backend: %s\n", color.New(color.Bold).Sprint(s.Backend()))
+		return
+	}
+
+	from := s.Backend()
+	if err := s.MigrateBackend(args[0]); err != nil {
+		er(fmt.Sprintf("Failed to migrate backend: %v", err))
+		return
+	}
+
+	fmt.Printf("Ledger moved from %s to %s backend\n", from, color.New(color.Bold).Sprint(args[0]))
+}
@@ -0,0 +1,203 @@
+package wago
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/model"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+func init() {
+	// Backup command
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Manage automatic backups",
+		Long: `List, inspect and restore the snapshots taken before every change to the data file.
+Set WAGO_BACKUPS to the number of snapshots to keep (default 10, 0 disables).`,
+		Run: listBackups,
+	}
+
+	// List subcommand
+	listBackupCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List backups",
+		Long:  `List backups, newest first.`,
+		Args:  cobra.NoArgs,
+		Run:   listBackups,
+	}
+
+	// Restore subcommand
+	restoreBackupCmd := &cobra.Command{
+		Use:   "restore [id]",
+		Short: "Restore a backup",
+		Long:  `Replace the data file with a backup. The current data is backed up first.`,
+		Args:  cobra.ExactArgs(1),
+		Run:   restoreBackup,
+	}
+
+	// Diff subcommand
+	diffBackupCmd := &cobra.Command{
+		Use:   "diff [id]",
+		Short: "Show changes since a backup",
+		Long:  `Show which wallets, balances, transactions and prices changed between a backup and the current data.`,
+		Args:  cobra.ExactArgs(1),
+		Run:   diffBackup,
+	}
+
+	// Add subcommands to backup command
+	backupCmd.AddCommand(listBackupCmd)
+	backupCmd.AddCommand(restoreBackupCmd)
+	backupCmd.AddCommand(diffBackupCmd)
+
+	// Add backup command to root command
+	rootCmd.AddCommand(backupCmd)
+}
+
+func listBackups(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		er(fmt.Sprintf("Failed to list backups: %v", err))
+		return
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups found")
+		return
+	}
+
+	fmt.Println(color.New(color.Bold).Sprint("Backups:"))
+	for _, backup := range backups {
+		fmt.Printf("  %s %s %s\n",
+			color.New(color.Bold).Sprint(backup.ID),
+			color.New(color.FgHiBlack).Sprintf("[%s]", backup.Time.Format("2006-01-02 15:04:05")),
+			color.New(color.FgHiBlack).Sprintf("%.1f KB", float64(backup.Size)/1024))
+	}
+}
+
+func restoreBackup(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	if err := s.RestoreBackup(args[0]); err != nil {
+		er(fmt.Sprintf("Failed to restore backup: %v", err))
+		return
+	}
+
+	fmt.Printf("Backup '%s' restored successfully\n", args[0])
+}
+
+func diffBackup(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	diff, err := s.DiffBackup(args[0])
+	if err != nil {
+		er(fmt.Sprintf("Failed to compare backup: %v", err))
+		return
+	}
+	if diff.Empty() {
+		fmt.Println("No changes since this backup")
+		return
+	}
+
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+	bold := color.New(color.Bold)
+
+	if len(diff.WalletsAdded) > 0 || len(diff.WalletsRemoved) > 0 {
+		bold.Println("Wallets:")
+		for _, name := range diff.WalletsAdded {
+			fmt.Printf("  %s %s\n", green.Sprint("+"), name)
+		}
+		for _, name := range diff.WalletsRemoved {
+			fmt.Printf("  %s %s\n", red.Sprint("-"), name)
+		}
+	}
+
+	if len(diff.BalanceChanges) > 0 {
+		bold.Println("Balances:")
+		for _, change := range diff.BalanceChanges {
+			delta := change.New.Sub(change.Old)
+			deltaColor := green
+			sign := "+"
+			if delta.IsNegative() {
+				deltaColor = red
+				sign = ""
+			}
+			fmt.Printf("  %s %s: %s → %s %s\n",
+				change.Wallet,
+				bold.Sprint(change.Coin),
+				change.Old.StringFixed(2),
+				change.New.StringFixed(2),
+				deltaColor.Sprintf("(%s%s)", sign, delta.StringFixed(2)))
+		}
+	}
+
+	if len(diff.TxsAdded) > 0 || len(diff.TxsRemoved) > 0 {
+		bold.Println("Transactions:")
+		for _, tx := range diff.TxsAdded {
+			fmt.Printf("  %s %s %s\n", green.Sprint("+"), tx.ID, txSummary(tx))
+		}
+		for _, tx := range diff.TxsRemoved {
+			fmt.Printf("  %s %s %s\n", red.Sprint("-"), tx.ID, txSummary(tx))
+		}
+	}
+
+	if len(diff.PriceChanges) > 0 {
+		bold.Println("Prices:")
+		for _, change := range diff.PriceChanges {
+			coin := strings.ToUpper(change.Coin)
+			switch {
+			case change.Old == 0:
+				fmt.Printf("  %s %s: $%.2f\n", green.Sprint("+"), coin, change.New)
+			case change.New == 0:
+				fmt.Printf("  %s %s: $%.2f\n", red.Sprint("-"), coin, change.Old)
+			default:
+				fmt.Printf("  ~ %s: $%.2f → $%.2f\n", coin, change.Old, change.New)
+			}
+		}
+	}
+}
+
+// txSummary describes a transaction on one line without colors
+func txSummary(tx *model.Tx) string {
+	date := tx.Date.Local().Format("2006-01-02 15:04")
+	switch tx.Type {
+	case model.TxTypeDeposit:
+		return fmt.Sprintf("deposit %s %s to %s [%s]", tx.Amount.StringFixed(2), tx.Coin, tx.ToWallet, date)
+	case model.TxTypeWithdraw:
+		return fmt.Sprintf("withdraw %s %s from %s [%s]", tx.Amount.StringFixed(2), tx.Coin, tx.FromWallet, date)
+	case model.TxTypeTransfer:
+		return fmt.Sprintf("transfer %s %s from %s to %s [%s]", tx.Amount.StringFixed(2), tx.Coin, tx.FromWallet, tx.ToWallet, date)
+	case model.TxTypeSwap:
+		return fmt.Sprintf("swap %s %s → %s %s in %s [%s]", tx.SellAmount.StringFixed(2), tx.SellCoin, tx.BuyAmount.StringFixed(2), tx.BuyCoin, tx.SwapWallet, date)
+	case model.TxTypeStake, model.TxTypeUnstake:
+		return fmt.Sprintf("%s %s %s in %s [%s]", tx.Type, tx.Amount.StringFixed(2), tx.Coin, tx.FromWallet, date)
+	case model.TxTypeLPAdd, model.TxTypeLPRemove:
+		return fmt.Sprintf("%s %s %s + %s %s %s %s [%s]", tx.Type, tx.Amount.StringFixed(2), tx.Coin, tx.PairAmount.StringFixed(2), tx.PairCoin, tx.FromWallet, tx.Pool, date)
+	case model.TxTypeMulti:
+		return fmt.Sprintf("multi %d legs %s [%s]", len(tx.Legs), tx.ID, date)
+	case model.TxTypeBridge:
+		return fmt.Sprintf("bridge %s %s from %s to %s [%s]", tx.Amount.StringFixed(2), tx.Coin, tx.FromWallet, tx.ToWallet, date)
+	case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+		return fmt.Sprintf("%s %s %s to %s [%s]", tx.Type, tx.Amount.StringFixed(2), tx.Coin, tx.ToWallet, date)
+	}
+	return fmt.Sprintf("%s [%s]", tx.Type, date)
+}
@@ -18,7 +18,7 @@ func init() {
 		Use:     "category",
 		Aliases: []string{"cat"},
 		Short:   "Manage wallet categories",
-		Long:    `Add and delete wallet categories.`,
+		Long:    `Add, delete, rename, and archive wallet categories.`,
 		Run:     listCategories,
 	}
 
@@ -40,9 +40,28 @@ func init() {
 		Run:   deleteCategory,
 	}
 
+	// Rename subcommand
+	renCategoryCmd := &cobra.Command{
+		Use:   "rename [old] [new]",
+		Short: "Rename a category",
+		Long:  `Rename a category and move its wallets to the new name.`,
+		Args:  cobra.ExactArgs(2),
+		Run:   renameCategory,
+	}
+
+	archiveCategoryCmd, unarchiveCategoryCmd := newArchiveCmds("category", func(s storage.Store, name string, archived bool) error {
+		return s.SetCategoryArchived(name, archived)
+	})
+
+	// Add flags to category list command
+	categoryCmd.Flags().BoolVar(&showArchived, "all", false, "Include archived categories")
+
 	// Add subcommands to category command
 	categoryCmd.AddCommand(addCategoryCmd)
 	categoryCmd.AddCommand(delCategoryCmd)
+	categoryCmd.AddCommand(renCategoryCmd)
+	categoryCmd.AddCommand(archiveCategoryCmd)
+	categoryCmd.AddCommand(unarchiveCategoryCmd)
 
 	// Add category command to root command
 	rootCmd.AddCommand(categoryCmd)
@@ -54,6 +73,7 @@ func addCategory(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	name := args[0]
 	category := &model.Category{
@@ -75,6 +95,7 @@ func deleteCategory(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	name := args[0]
 	if err := s.DeleteCategory(name); err != nil {
@@ -85,14 +106,35 @@ func deleteCategory(cmd *cobra.Command, args []string) {
 	fmt.Printf("Category '%s' deleted successfully\n", name)
 }
 
+func renameCategory(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	oldName, newName := args[0], args[1]
+	if err := s.RenameCategory(oldName, newName); err != nil {
+		er(fmt.Sprintf("Failed to rename category: %v", err))
+		return
+	}
+
+	fmt.Printf("Category '%s' renamed to '%s'\n", oldName, newName)
+}
+
 func listCategories(cmd *cobra.Command, args []string) {
 	s, err := storage.New()
 	if err != nil {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	categories := s.ListCategories()
+	if !showArchived {
+		categories = activeCategories(categories)
+	}
 	if len(categories) == 0 {
 		fmt.Println("No categories found")
 		return
@@ -108,6 +150,9 @@ func listCategories(cmd *cobra.Command, args []string) {
 		
 		// Create a colored category name using the category's color
 		categoryName := category.Name
+		if category.Archived {
+			categoryName += " [archived]"
+		}
 		
 		// Use the color name to create a terminal color if possible
 		if colorName != "" {
@@ -7,30 +7,36 @@ import (
 	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/vasylcode/wago/internal/model"
 	"github.com/vasylcode/wago/internal/storage"
+	"github.com/vasylcode/wago/internal/util"
 )
 
 // CommandResult represents the result of a command execution
 type CommandResult struct {
 	Success  bool
 	Message  string
-	IsHelp   bool   // Show as popup
-	HelpText string // Multi-line help content
-	Quit     bool   // Signal to quit app
+	IsHelp   bool     // Show as popup
+	HelpText string   // Multi-line help content
+	Quit     bool     // Signal to quit app
+	Switched bool     // Active profile changed
+	Filtered bool     // Tag filter of the stats view changed
+	Filter   []string // Tags the stats view is filtered by, nil for all
 }
 
 // CommandPalette handles command parsing and execution
 type CommandPalette struct {
-	storage *storage.Storage
+	storage storage.Store
+	date    time.Time // Date of transactions added by the running command
+	tags    []string  // Tags of transactions added by the running command
 	history []string
 	histIdx int
 }
 
 // NewCommandPalette creates a new command palette
-func NewCommandPalette(s *storage.Storage) *CommandPalette {
+func NewCommandPalette() *CommandPalette {
 	return &CommandPalette{
-		storage: s,
 		history: []string{},
 		histIdx: -1,
 	}
@@ -56,13 +62,67 @@ func (cp *CommandPalette) Execute(input string) CommandResult {
 	cmd := strings.ToLower(parts[0])
 	args := parts[1:]
 
+	// #tag tokens tag the transactions a command records
+	cp.tags = nil
+	var rest []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "#") && len(arg) > 1 {
+			cp.tags = append(cp.tags, arg)
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	args = rest
+	tags, err := storage.NormalizeTags(cp.tags)
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	cp.tags = tags
+
+	// A trailing @date backdates the transactions a command records; #tags
+	// may come before or after it
+	cp.date = time.Now()
+	if n := len(args); n > 0 && strings.HasPrefix(args[n-1], "@") && len(args[n-1]) > 1 {
+		date, err := util.ParseDate(args[n-1][1:])
+		if err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+		}
+		cp.date, args = date, args[:n-1]
+	}
+
+	// Commands that don't touch the ledger
 	switch cmd {
 	case "q", "quit", "exit":
 		return CommandResult{Quit: true}
+	case "help", "h", "?":
+		return cp.cmdHelp()
+	case "profile", "pr":
+		return cp.cmdProfile(args)
+	case "filter", "f":
+		return cp.cmdFilter(args)
+	}
+
+	// Hold the storage lock only while the command runs
+	s, err := storage.New()
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	defer s.Close()
+	cp.storage = s
+
+	switch cmd {
 	case "add", "a":
 		return cp.cmdAdd(args)
 	case "del", "d", "delete", "rm":
 		return cp.cmdDelete(args)
+	case "edit", "e":
+		return cp.cmdEdit(args)
+	case "rename", "mv":
+		return cp.cmdRename(args)
+	case "archive":
+		return cp.cmdArchive(args, true)
+	case "unarchive":
+		return cp.cmdArchive(args, false)
 	case "deposit", "dep":
 		return cp.cmdDeposit(args)
 	case "withdraw", "wd":
@@ -71,12 +131,32 @@ func (cp *CommandPalette) Execute(input string) CommandResult {
 		return cp.cmdTransfer(args)
 	case "swap", "sw":
 		return cp.cmdSwap(args)
+	case "lp-add", "lpa":
+		return cp.cmdLP(model.TxTypeLPAdd, args)
+	case "lp-remove", "lpr":
+		return cp.cmdLP(model.TxTypeLPRemove, args)
+	case "multi", "mx":
+		return cp.cmdMulti(args)
+	case "stake", "stk":
+		return cp.cmdWalletTx(model.TxTypeStake, args)
+	case "unstake", "ustk":
+		return cp.cmdWalletTx(model.TxTypeUnstake, args)
+	case "reward", "rw":
+		return cp.cmdWalletTx(model.TxTypeReward, args)
+	case "airdrop", "ad":
+		return cp.cmdWalletTx(model.TxTypeAirdrop, args)
+	case "income", "inc":
+		return cp.cmdWalletTx(model.TxTypeIncome, args)
 	case "balance", "bal", "b":
 		return cp.cmdBalance(args)
 	case "price", "p":
 		return cp.cmdPrice(args)
-	case "help", "h", "?":
-		return cp.cmdHelp()
+	case "run":
+		return cp.cmdRun()
+	case "undo", "u":
+		return cp.cmdUndo()
+	case "redo":
+		return cp.cmdRedo()
 	default:
 		return CommandResult{Success: false, Message: fmt.Sprintf("Unknown command: %s (:help for commands)", cmd)}
 	}
@@ -115,7 +195,7 @@ func (cp *CommandPalette) cmdAdd(args []string) CommandResult {
 		if len(subArgs) < 3 {
 			return CommandResult{Success: false, Message: "Usage: add wallet NAME ADDR CHAIN-TYPE (CAT) (NOTE)"}
 		}
-		
+
 		// Parse chain-type (e.g., "solana-hot" -> chain="solana", type="hot")
 		chainType := subArgs[2]
 		chain := chainType
@@ -124,7 +204,7 @@ func (cp *CommandPalette) cmdAdd(args []string) CommandResult {
 			chain = chainType[:idx]
 			walletType = chainType[idx+1:]
 		}
-		
+
 		wallet := &model.Wallet{
 			Name:    subArgs[0],
 			Address: subArgs[1],
@@ -147,11 +227,11 @@ func (cp *CommandPalette) cmdAdd(args []string) CommandResult {
 		if len(subArgs) < 1 {
 			return CommandResult{Success: false, Message: "Usage: add category NAME (COLOR)"}
 		}
-		
+
 		// Random color if not specified
 		colors := []string{"red", "green", "blue", "yellow", "magenta", "cyan", "orange", "pink", "purple"}
 		randomColor := colors[rand.Intn(len(colors))]
-		
+
 		cat := &model.Category{
 			Name:  subArgs[0],
 			Color: randomColor,
@@ -191,7 +271,7 @@ func (cp *CommandPalette) cmdAdd(args []string) CommandResult {
 
 func (cp *CommandPalette) cmdDelete(args []string) CommandResult {
 	if len(args) < 2 {
-		return CommandResult{Success: false, Message: "Usage: del wallet|category|contact|tx NAME|ID"}
+		return CommandResult{Success: false, Message: "Usage: del wallet|category|contact|tx NAME|ID (cascade|archive)"}
 	}
 
 	sub := strings.ToLower(args[0])
@@ -199,9 +279,24 @@ func (cp *CommandPalette) cmdDelete(args []string) CommandResult {
 
 	switch sub {
 	case "wallet", "w":
-		if err := cp.storage.DeleteWallet(name); err != nil {
+		// del wallet <name> [cascade|archive]
+		policy := storage.DeleteRefuse
+		if len(args) > 2 {
+			switch strings.ToLower(args[2]) {
+			case "cascade":
+				policy = storage.DeleteCascade
+			case "archive":
+				policy = storage.DeleteArchive
+			default:
+				return CommandResult{Success: false, Message: "Usage: del wallet NAME (cascade|archive)"}
+			}
+		}
+		if err := cp.storage.DeleteWallet(name, policy); err != nil {
 			return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 		}
+		if policy == storage.DeleteArchive {
+			return CommandResult{Success: true, Message: fmt.Sprintf("Archived wallet: %s", name)}
+		}
 		return CommandResult{Success: true, Message: fmt.Sprintf("Deleted wallet: %s", name)}
 
 	case "category", "cat", "c":
@@ -227,6 +322,104 @@ func (cp *CommandPalette) cmdDelete(args []string) CommandResult {
 	}
 }
 
+func (cp *CommandPalette) cmdRename(args []string) CommandResult {
+	// rename wallet|category|contact <old> <new>
+	if len(args) < 3 {
+		return CommandResult{Success: false, Message: "Usage: rename wallet|category|contact OLD NEW"}
+	}
+
+	sub := strings.ToLower(args[0])
+	oldName, newName := args[1], args[2]
+
+	var err error
+	switch sub {
+	case "wallet", "w":
+		sub = "wallet"
+		err = cp.storage.RenameWallet(oldName, newName)
+	case "category", "cat", "c":
+		sub = "category"
+		err = cp.storage.RenameCategory(oldName, newName)
+	case "contact", "con":
+		sub = "contact"
+		err = cp.storage.RenameContact(oldName, newName)
+	default:
+		return CommandResult{Success: false, Message: fmt.Sprintf("Unknown type: %s (use wallet, category, or contact)", sub)}
+	}
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Renamed %s: %s -> %s", sub, oldName, newName)}
+}
+
+func (cp *CommandPalette) cmdEdit(args []string) CommandResult {
+	// edit tx <id> field=value...; words without '=' continue the previous value
+	if len(args) < 3 || !(strings.EqualFold(args[0], "tx") || strings.EqualFold(args[0], "transaction")) {
+		return CommandResult{Success: false, Message: "Usage: edit tx ID FIELD=VALUE..."}
+	}
+
+	stored, err := cp.storage.GetTransaction(args[1])
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+
+	var fields, values []string
+	for _, arg := range args[2:] {
+		if field, value, ok := strings.Cut(arg, "="); ok {
+			fields = append(fields, strings.ToLower(field))
+			values = append(values, value)
+		} else if len(values) > 0 {
+			values[len(values)-1] += " " + arg
+		} else {
+			return CommandResult{Success: false, Message: "Usage: edit tx ID FIELD=VALUE..."}
+		}
+	}
+
+	// Edit a copy so storage still knows the old wallets
+	tx := *stored
+	for i, field := range fields {
+		if err := setTxField(cp.storage, &tx, field, values[i]); err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+		}
+	}
+	if err := cp.storage.UpdateTransaction(&tx); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Edited transaction: %s", tx.ID)}
+}
+
+func (cp *CommandPalette) cmdArchive(args []string, archived bool) CommandResult {
+	// archive|unarchive wallet|category|contact <name>
+	action := "archive"
+	if !archived {
+		action = "unarchive"
+	}
+	if len(args) < 2 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Usage: %s wallet|category|contact NAME", action)}
+	}
+
+	sub := strings.ToLower(args[0])
+	name := args[1]
+
+	var err error
+	switch sub {
+	case "wallet", "w":
+		sub = "wallet"
+		err = cp.storage.SetWalletArchived(name, archived)
+	case "category", "cat", "c":
+		sub = "category"
+		err = cp.storage.SetCategoryArchived(name, archived)
+	case "contact", "con":
+		sub = "contact"
+		err = cp.storage.SetContactArchived(name, archived)
+	default:
+		return CommandResult{Success: false, Message: fmt.Sprintf("Unknown type: %s (use wallet, category, or contact)", sub)}
+	}
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("%sd %s: %s", strings.Title(action), sub, name)}
+}
+
 func (cp *CommandPalette) cmdDeposit(args []string) CommandResult {
 	// deposit <wallet> <amount> <coin> [note]
 	if len(args) < 3 {
@@ -234,7 +427,7 @@ func (cp *CommandPalette) cmdDeposit(args []string) CommandResult {
 	}
 
 	wallet := args[0]
-	amount, err := strconv.ParseFloat(args[1], 64)
+	amount, err := util.ParseAmount(args[1])
 	if err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid amount: %s", args[1])}
 	}
@@ -246,16 +439,17 @@ func (cp *CommandPalette) cmdDeposit(args []string) CommandResult {
 		ToWallet: wallet,
 		Coin:     coin,
 		Amount:   amount,
-		Date:     time.Now(),
+		Date:     cp.date,
 	}
 	if len(args) > 3 {
 		tx.Note = strings.Join(args[3:], " ")
 	}
 
+	tx.Tags = cp.tags
 	if err := cp.storage.AddTransaction(tx); err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 	}
-	return CommandResult{Success: true, Message: fmt.Sprintf("Deposited %.2f %s to %s", amount, coin, wallet)}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Deposited %s %s to %s", amount.StringFixed(2), coin, wallet)}
 }
 
 func (cp *CommandPalette) cmdWithdraw(args []string) CommandResult {
@@ -265,7 +459,7 @@ func (cp *CommandPalette) cmdWithdraw(args []string) CommandResult {
 	}
 
 	wallet := args[0]
-	amount, err := strconv.ParseFloat(args[1], 64)
+	amount, err := util.ParseAmount(args[1])
 	if err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid amount: %s", args[1])}
 	}
@@ -277,16 +471,17 @@ func (cp *CommandPalette) cmdWithdraw(args []string) CommandResult {
 		FromWallet: wallet,
 		Coin:       coin,
 		Amount:     amount,
-		Date:       time.Now(),
+		Date:       cp.date,
 	}
 	if len(args) > 3 {
 		tx.Note = strings.Join(args[3:], " ")
 	}
 
+	tx.Tags = cp.tags
 	if err := cp.storage.AddTransaction(tx); err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 	}
-	return CommandResult{Success: true, Message: fmt.Sprintf("Withdrew %.2f %s from %s", amount, coin, wallet)}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Withdrew %s %s from %s", amount.StringFixed(2), coin, wallet)}
 }
 
 func (cp *CommandPalette) cmdTransfer(args []string) CommandResult {
@@ -297,7 +492,7 @@ func (cp *CommandPalette) cmdTransfer(args []string) CommandResult {
 
 	from := args[0]
 	to := args[1]
-	amount, err := strconv.ParseFloat(args[2], 64)
+	amount, err := util.ParseAmount(args[2])
 	if err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid amount: %s", args[2])}
 	}
@@ -310,16 +505,17 @@ func (cp *CommandPalette) cmdTransfer(args []string) CommandResult {
 		ToWallet:   to,
 		Coin:       coin,
 		Amount:     amount,
-		Date:       time.Now(),
+		Date:       cp.date,
 	}
 	if len(args) > 4 {
 		tx.Note = strings.Join(args[4:], " ")
 	}
 
+	tx.Tags = cp.tags
 	if err := cp.storage.AddTransaction(tx); err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 	}
-	return CommandResult{Success: true, Message: fmt.Sprintf("Transferred %.2f %s: %s → %s", amount, coin, from, to)}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Transferred %s %s: %s → %s", amount.StringFixed(2), coin, from, to)}
 }
 
 func (cp *CommandPalette) cmdSwap(args []string) CommandResult {
@@ -329,12 +525,12 @@ func (cp *CommandPalette) cmdSwap(args []string) CommandResult {
 	}
 
 	wallet := args[0]
-	sellAmount, err := strconv.ParseFloat(args[1], 64)
+	sellAmount, err := util.ParseAmount(args[1])
 	if err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid sell amount: %s", args[1])}
 	}
 	sellCoin := strings.ToUpper(args[2])
-	buyAmount, err := strconv.ParseFloat(args[3], 64)
+	buyAmount, err := util.ParseAmount(args[3])
 	if err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid buy amount: %s", args[3])}
 	}
@@ -348,16 +544,135 @@ func (cp *CommandPalette) cmdSwap(args []string) CommandResult {
 		SellAmount: sellAmount,
 		BuyCoin:    buyCoin,
 		BuyAmount:  buyAmount,
-		Date:       time.Now(),
+		Date:       cp.date,
 	}
 	if len(args) > 5 {
 		tx.Note = strings.Join(args[5:], " ")
 	}
 
+	tx.Tags = cp.tags
+	if err := cp.storage.AddTransaction(tx); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Swapped %s %s → %s %s in %s", sellAmount.StringFixed(2), sellCoin, buyAmount.StringFixed(2), buyCoin, wallet)}
+}
+
+func (cp *CommandPalette) cmdLP(txType model.TxType, args []string) CommandResult {
+	// lp-add|lp-remove <wallet> <pool> <amount> <coin> <pair_amount> <pair_coin> <lp_tokens>
+	if len(args) < 7 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Usage: %s WALLET POOL AMOUNT COIN PAIR_AMOUNT PAIR_COIN LP_TOKENS", txType)}
+	}
+
+	amounts := make([]decimal.Decimal, 3)
+	for i, arg := range []string{args[2], args[4], args[6]} {
+		amount, err := util.ParseAmount(arg)
+		if err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Invalid amount: %s", arg)}
+		}
+		amounts[i] = amount
+	}
+
+	tx := &model.Tx{
+		ID:         cp.storage.GenerateTxID(),
+		Type:       txType,
+		FromWallet: args[0],
+		Pool:       args[1],
+		Coin:       strings.ToUpper(args[3]),
+		Amount:     amounts[0],
+		PairCoin:   strings.ToUpper(args[5]),
+		PairAmount: amounts[1],
+		LPTokens:   amounts[2],
+		Date:       cp.date,
+	}
+	tx.Tags = cp.tags
+	if err := cp.storage.AddTransaction(tx); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+
+	if txType == model.TxTypeLPAdd {
+		return CommandResult{Success: true, Message: fmt.Sprintf("Added %s %s + %s %s to %s for %s LP", tx.Amount.StringFixed(2), tx.Coin, tx.PairAmount.StringFixed(2), tx.PairCoin, tx.Pool, tx.LPTokens)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Removed %s LP from %s for %s %s + %s %s", tx.LPTokens, tx.Pool, tx.Amount.StringFixed(2), tx.Coin, tx.PairAmount.StringFixed(2), tx.PairCoin)}
+}
+
+func (cp *CommandPalette) cmdMulti(args []string) CommandResult {
+	// multi <wallet:amount:coin>... [note]
+	if len(args) < 1 {
+		return CommandResult{Success: false, Message: "Usage: multi WALLET:AMOUNT:COIN... (NOTE)"}
+	}
+
+	// Legs come first, anything after them is the note
+	n := 0
+	for n < len(args) && strings.Count(args[n], ":") == 2 {
+		n++
+	}
+	legs, err := parseLegs(strings.Join(args[:n], ","))
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+
+	tx := &model.Tx{
+		ID:   cp.storage.GenerateTxID(),
+		Type: model.TxTypeMulti,
+		Legs: legs,
+		Date: cp.date,
+	}
+	if len(args) > n {
+		tx.Note = strings.Join(args[n:], " ")
+	}
+
+	tx.Tags = cp.tags
+	if err := cp.storage.AddTransaction(tx); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Recorded %d legs as %s", len(legs), tx.ID)}
+}
+
+func (cp *CommandPalette) cmdWalletTx(txType model.TxType, args []string) CommandResult {
+	// stake|unstake|reward|airdrop|income <wallet> <amount> <coin> [note]
+	if len(args) < 3 {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Usage: %s WALLET AMOUNT COIN (NOTE)", txType)}
+	}
+
+	wallet := args[0]
+	amount, err := util.ParseAmount(args[1])
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid amount: %s", args[1])}
+	}
+	coin := strings.ToUpper(args[2])
+
+	tx := &model.Tx{
+		ID:     cp.storage.GenerateTxID(),
+		Type:   txType,
+		Coin:   coin,
+		Amount: amount,
+		Date:   cp.date,
+	}
+	// Staking moves funds within the wallet, earnings are received by it
+	if txType == model.TxTypeStake || txType == model.TxTypeUnstake {
+		tx.FromWallet = wallet
+	} else {
+		tx.ToWallet = wallet
+	}
+	if len(args) > 3 {
+		tx.Note = strings.Join(args[3:], " ")
+	}
+
+	tx.Tags = cp.tags
 	if err := cp.storage.AddTransaction(tx); err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 	}
-	return CommandResult{Success: true, Message: fmt.Sprintf("Swapped %.2f %s → %.2f %s in %s", sellAmount, sellCoin, buyAmount, buyCoin, wallet)}
+
+	var msg string
+	switch txType {
+	case model.TxTypeStake:
+		msg = fmt.Sprintf("Staked %s %s in %s", amount.StringFixed(2), coin, wallet)
+	case model.TxTypeUnstake:
+		msg = fmt.Sprintf("Unstaked %s %s in %s", amount.StringFixed(2), coin, wallet)
+	default:
+		msg = fmt.Sprintf("Recorded %s of %s %s to %s", txType, amount.StringFixed(2), coin, wallet)
+	}
+	return CommandResult{Success: true, Message: msg}
 }
 
 func (cp *CommandPalette) cmdBalance(args []string) CommandResult {
@@ -367,7 +682,7 @@ func (cp *CommandPalette) cmdBalance(args []string) CommandResult {
 	}
 
 	walletName := args[0]
-	amount, err := strconv.ParseFloat(args[1], 64)
+	amount, err := util.ParseAmount(args[1])
 	if err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Invalid amount: %s", args[1])}
 	}
@@ -378,23 +693,41 @@ func (cp *CommandPalette) cmdBalance(args []string) CommandResult {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 	}
 
-	// Find or create balance
-	found := false
+	// Record the difference as an adjustment so the journal stays the
+	// source of truth for balances. A backdated adjustment sets the
+	// balance as of its date.
 	for _, bal := range wallet.Balances {
-		if strings.EqualFold(bal.Coin, coin) {
-			bal.Amount = amount
-			found = true
+		if !bal.Staked && strings.EqualFold(bal.Coin, coin) {
+			coin = bal.Coin
 			break
 		}
 	}
-	if !found {
-		wallet.Balances = append(wallet.Balances, &model.Balance{Coin: coin, Amount: amount})
+	diff := amount.Sub(cp.storage.BalanceAt(walletName, coin, cp.date))
+	if diff.IsZero() {
+		return CommandResult{Success: true, Message: fmt.Sprintf("%s balance is already %s %s", walletName, amount.StringFixed(2), coin)}
 	}
 
-	if err := cp.storage.UpdateWallet(walletName, wallet); err != nil {
+	tx := &model.Tx{
+		ID:     cp.storage.GenerateTxID(),
+		Type:   model.TxTypeDeposit,
+		Coin:   coin,
+		Amount: diff,
+		Date:   cp.date,
+		Note:   "Balance adjustment",
+	}
+	if diff.IsPositive() {
+		tx.ToWallet = walletName
+	} else {
+		tx.Type = model.TxTypeWithdraw
+		tx.FromWallet = walletName
+		tx.Amount = diff.Neg()
+	}
+
+	tx.Tags = cp.tags
+	if err := cp.storage.AddTransaction(tx); err != nil {
 		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
 	}
-	return CommandResult{Success: true, Message: fmt.Sprintf("Set %s balance: %.2f %s", walletName, amount, coin)}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Set %s balance: %s %s", walletName, amount.StringFixed(2), coin)}
 }
 
 func (cp *CommandPalette) cmdPrice(args []string) CommandResult {
@@ -415,6 +748,87 @@ func (cp *CommandPalette) cmdPrice(args []string) CommandResult {
 	return CommandResult{Success: true, Message: fmt.Sprintf("Set %s price: $%.2f", strings.ToUpper(coin), price)}
 }
 
+func (cp *CommandPalette) cmdUndo() CommandResult {
+	summary, err := cp.storage.Undo()
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Undone: %s", summary)}
+}
+
+func (cp *CommandPalette) cmdRun() CommandResult {
+	recorded, err := cp.storage.RunSchedules(time.Now())
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Recorded %d scheduled transaction(s)", len(recorded))}
+}
+
+func (cp *CommandPalette) cmdRedo() CommandResult {
+	summary, err := cp.storage.Redo()
+	if err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Message: fmt.Sprintf("Redone: %s", summary)}
+}
+
+func (cp *CommandPalette) cmdProfile(args []string) CommandResult {
+	// profile [name] [new]
+	if len(args) < 1 {
+		profiles, err := storage.ListProfiles()
+		if err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+		}
+		current := storage.CurrentProfile()
+		for i, name := range profiles {
+			if name == current {
+				profiles[i] = "*" + name
+			}
+		}
+		return CommandResult{Success: true, Message: "Profiles: " + strings.Join(profiles, " ")}
+	}
+
+	create := len(args) == 2 && strings.ToLower(args[1]) == "new"
+	if len(args) > 2 || (len(args) == 2 && !create) {
+		return CommandResult{Success: false, Message: "Usage: profile NAME (new)"}
+	}
+
+	// A typo must not silently start an empty ledger
+	name := args[0]
+	if !create {
+		profiles, err := storage.ListProfiles()
+		if err != nil {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+		}
+		found := false
+		for _, profile := range profiles {
+			if profile == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return CommandResult{Success: false, Message: fmt.Sprintf("Error: profile '%s' not found (use: profile %s new)", name, name)}
+		}
+	}
+
+	if err := storage.SetProfile(name); err != nil {
+		return CommandResult{Success: false, Message: fmt.Sprintf("Error: %v", err)}
+	}
+	return CommandResult{Success: true, Switched: true, Message: fmt.Sprintf("Switched to profile: %s", storage.CurrentProfile())}
+}
+
+func (cp *CommandPalette) cmdFilter(args []string) CommandResult {
+	// filter (#tag...), which Execute has already pulled into cp.tags
+	if len(args) > 0 {
+		return CommandResult{Success: false, Message: "Usage: filter #TAG... (no tags to clear)"}
+	}
+	if len(cp.tags) == 0 {
+		return CommandResult{Success: true, Filtered: true, Message: "Tag filter cleared"}
+	}
+	return CommandResult{Success: true, Filtered: true, Filter: cp.tags, Message: fmt.Sprintf("Filtered by %s", formatTags(cp.tags))}
+}
+
 func (cp *CommandPalette) cmdHelp() CommandResult {
 	help := `[yellow]Commands:[white]
 
@@ -424,18 +838,34 @@ func (cp *CommandPalette) cmdHelp() CommandResult {
 [green]add contact[white] NAME ADDR (CHAIN) (NOTE)
 
 [green]del[white] wallet|category|contact|tx NAME|ID
+[green]del wallet[white] NAME cascade|archive
+[green]rename[white] wallet|category|contact OLD NEW
+[green]edit tx[white] ID FIELD=VALUE (amount coin fee date note from to ...)
+[green]archive[white] / [green]unarchive[white] wallet|category|contact NAME
 
 [green]deposit[white] WALLET AMOUNT COIN (NOTE)
 [green]withdraw[white] WALLET AMOUNT COIN (NOTE)
 [green]transfer[white] FROM TO AMOUNT COIN (NOTE)
 [green]swap[white] WALLET SELL_AMT SELL_COIN BUY_AMT BUY_COIN
+[green]multi[white] WALLET:AMOUNT:COIN... (NOTE)
+[green]lp-add[white] / [green]lp-remove[white] WALLET POOL AMOUNT COIN PAIR_AMOUNT PAIR_COIN LP_TOKENS
+[green]stake[white] / [green]unstake[white] WALLET AMOUNT COIN (NOTE)
+[green]reward[white] / [green]airdrop[white] / [green]income[white] WALLET AMOUNT COIN (NOTE)
 
 [green]balance[white] WALLET AMOUNT COIN
+  end any of these with [green]@DATE[white] to backdate: @2024-03-01 @yesterday @-3d
+  and add [green]#TAG[white] anywhere to tag it: #grant-x #client-acme
 [green]price[white] COIN USD_PRICE
 
+[green]run[white]  record due scheduled transactions
+[green]undo[white] / [green]redo[white]
+[green]profile[white] (NAME) (new)
+[green]filter[white] (#TAG...)  show only tagged transactions in stats
 [green]q[white] quit
 
-[yellow]Shortcuts:[white] a=add d=del dep=deposit wd=withdraw
-          tf=transfer sw=swap b=balance p=price`
+[yellow]Shortcuts:[white] a=add d=del e=edit mv=rename dep=deposit wd=withdraw
+          tf=transfer sw=swap stk=stake ustk=unstake rw=reward ad=airdrop
+          inc=income mx=multi lpa=lp-add lpr=lp-remove b=balance p=price pr=profile u=undo
+          f=filter`
 	return CommandResult{Success: true, IsHelp: true, HelpText: help}
 }
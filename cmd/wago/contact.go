@@ -20,7 +20,7 @@ func init() {
 		Use:     "contact",
 		Aliases: []string{"c"},
 		Short:   "Manage contacts",
-		Long:    `Add, delete, and list contacts.`,
+		Long:    `Add, delete, rename, archive, and list contacts.`,
 		Run:     listContacts,
 	}
 
@@ -42,6 +42,15 @@ func init() {
 		Run:   deleteContact,
 	}
 
+	// Rename subcommand
+	renContactCmd := &cobra.Command{
+		Use:   "rename [old] [new]",
+		Short: "Rename a contact",
+		Long:  `Rename a contact and update the transfers that reference it.`,
+		Args:  cobra.ExactArgs(2),
+		Run:   renameContact,
+	}
+
 	// Add flags to add command
 	addContactCmd.Flags().StringVarP(&contactAddress, "address", "a", "", "Contact address")
 	addContactCmd.Flags().StringVarP(&contactChain, "chain", "n", "", "Blockchain")
@@ -50,9 +59,19 @@ func init() {
 	addContactCmd.MarkFlagRequired("address")
 	addContactCmd.MarkFlagRequired("chain")
 
+	archiveContactCmd, unarchiveContactCmd := newArchiveCmds("contact", func(s storage.Store, name string, archived bool) error {
+		return s.SetContactArchived(name, archived)
+	})
+
+	// Add flags to contact list command
+	contactCmd.Flags().BoolVar(&showArchived, "all", false, "Include archived contacts")
+
 	// Add subcommands to contact command
 	contactCmd.AddCommand(addContactCmd)
 	contactCmd.AddCommand(delContactCmd)
+	contactCmd.AddCommand(renContactCmd)
+	contactCmd.AddCommand(archiveContactCmd)
+	contactCmd.AddCommand(unarchiveContactCmd)
 
 	// Add contact command to root command
 	rootCmd.AddCommand(contactCmd)
@@ -64,6 +83,7 @@ func addContact(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	name := args[0]
 	contact := &model.Contact{
@@ -87,6 +107,7 @@ func deleteContact(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	name := args[0]
 	if err := s.DeleteContact(name); err != nil {
@@ -97,14 +118,35 @@ func deleteContact(cmd *cobra.Command, args []string) {
 	fmt.Printf("Contact '%s' deleted successfully\n", name)
 }
 
+func renameContact(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	oldName, newName := args[0], args[1]
+	if err := s.RenameContact(oldName, newName); err != nil {
+		er(fmt.Sprintf("Failed to rename contact: %v", err))
+		return
+	}
+
+	fmt.Printf("Contact '%s' renamed to '%s'\n", oldName, newName)
+}
+
 func listContacts(cmd *cobra.Command, args []string) {
 	s, err := storage.New()
 	if err != nil {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	contacts := s.ListContacts()
+	if !showArchived {
+		contacts = activeContacts(contacts)
+	}
 	if len(contacts) == 0 {
 		fmt.Println("No contacts found")
 		return
@@ -116,6 +158,9 @@ func listContacts(cmd *cobra.Command, args []string) {
 		if contact.Note != "" {
 			noteStr = fmt.Sprintf(" (%s)", contact.Note)
 		}
+		if contact.Archived {
+			noteStr += " [archived]"
+		}
 		
 		fmt.Printf("  %s (%s) %s%s\n", 
 			contact.Name, 
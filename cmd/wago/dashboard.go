@@ -9,6 +9,7 @@ import (
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 	"github.com/vasylcode/wago/internal/model"
 	"github.com/vasylcode/wago/internal/storage"
@@ -40,6 +41,7 @@ const (
 type StatsState struct {
 	Months       []string // sorted month keys (newest first)
 	CurrentMonth int      // index into Months
+	Tags         []string // only transactions with all of these tags, if any
 }
 
 // MainDashboardState holds the state for the main dashboard
@@ -61,7 +63,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 	mainState := &MainDashboardState{SelectedWallet: 0}
 
 	// buildMainDashboard creates the main dashboard UI
-	buildMainDashboard := func(s *storage.Storage, wallets []*model.Wallet, categories []*model.Category) *tview.Flex {
+	buildMainDashboard := func(s storage.Store, wallets []*model.Wallet, categories []*model.Category) *tview.Flex {
 		// Create a flex layout for the main container
 		flex := tview.NewFlex().SetDirection(tview.FlexRow)
 
@@ -69,7 +71,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 		header := tview.NewTextView().
 			SetTextAlign(tview.AlignCenter).
 			SetDynamicColors(true).
-			SetText("[::b][#00FFFF]Wallet Aggregator[white] [#666666]│[white] [#FF6600]Balances[white]")
+			SetText("[::b][#00FFFF]Wallet Aggregator[white] [#666666]│[white] [#FF6600]Balances[white]" + profileLabel())
 		header.SetBorder(true)
 		flex.AddItem(header, 3, 0, false)
 
@@ -100,7 +102,11 @@ func showDashboard(cmd *cobra.Command, args []string) {
 		topSection.AddItem(walletsView, 0, 30, false)
 
 		// Balances panel (20% width)
-		balancesView := createWalletBalancesPanel(selectedWallet)
+		var positions []*model.Position
+		if selectedWallet != nil {
+			positions = s.ListPositions(selectedWallet.Name)
+		}
+		balancesView := createWalletBalancesPanel(selectedWallet, positions)
 		topSection.AddItem(balancesView, 0, 20, false)
 
 		// Transactions panel (50% width)
@@ -114,18 +120,26 @@ func showDashboard(cmd *cobra.Command, args []string) {
 		// BOTTOM SECTION (20% height): Total Balance | Category Balance | Category Distribution
 		bottomSection := tview.NewFlex().SetDirection(tview.FlexColumn)
 
+		// Open LP positions of the wallets shown count towards the totals
+		openPositions := walletPositions(s, wallets)
+
 		// Total Balance by Coin (larger)
-		totalBalanceView := createTotalBalanceView(wallets)
+		totalBalanceView := createTotalBalanceView(wallets, openPositions)
 		bottomSection.AddItem(totalBalanceView, 0, 2, false)
 
 		// Balance by Category (smaller, middle)
-		categoryBalanceView := createCategoryBalanceView(wallets, categories)
+		categoryBalanceView := createCategoryBalanceView(wallets, categories, openPositions)
 		bottomSection.AddItem(categoryBalanceView, 0, 1, false)
 
 		// Category Distribution (larger)
 		categoryChartView := createCategoryChartView(wallets, categories)
 		bottomSection.AddItem(categoryChartView, 0, 2, false)
 
+		// Upcoming and overdue scheduled transactions, if any
+		if schedules := s.ListSchedules(); len(schedules) > 0 {
+			bottomSection.AddItem(createSchedulesView(schedules), 0, 2, false)
+		}
+
 		// Add sections to main flex
 		flex.AddItem(topSection, 0, 4, false)    // 80%
 		flex.AddItem(bottomSection, 0, 1, false) // 20%
@@ -134,7 +148,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 		footer := tview.NewTextView().
 			SetTextAlign(tview.AlignCenter).
 			SetDynamicColors(true).
-			SetText("[::b][#AAAAAA]Press [#FFFFFF]:[#AAAAAA] commands | [#FFFFFF]↑↓[#AAAAAA] select wallet | [#FFFFFF]Enter[#AAAAAA] copy addr | [#FFFFFF]s[#AAAAAA] stats | [#FFFFFF]r[#AAAAAA] reload")
+			SetText("[::b][#AAAAAA]Press [#FFFFFF]:[#AAAAAA] commands | [#FFFFFF]↑↓[#AAAAAA] select wallet | [#FFFFFF]Enter[#AAAAAA] copy addr | [#FFFFFF]s[#AAAAAA] stats | [#FFFFFF]u[#AAAAAA]/[#FFFFFF]^R[#AAAAAA] undo/redo | [#FFFFFF]r[#AAAAAA] reload")
 		footer.SetBorder(false)
 		flex.AddItem(footer, 1, 0, false)
 
@@ -142,9 +156,12 @@ func showDashboard(cmd *cobra.Command, args []string) {
 	}
 
 	// buildStatsDashboard creates the stats dashboard UI with month tabs
-	buildStatsDashboard := func(s *storage.Storage, wallets []*model.Wallet, categories []*model.Category) *tview.Flex {
+	buildStatsDashboard := func(s storage.Store, wallets []*model.Wallet, categories []*model.Category) *tview.Flex {
 		// Collect all transactions
 		allTxs := collectAllTransactions(s)
+		if len(statsState.Tags) > 0 {
+			allTxs = filterByTags(allTxs, statsState.Tags)
+		}
 
 		// Group transactions by month
 		txsByMonth := groupTransactionsByMonth(allTxs)
@@ -164,11 +181,15 @@ func showDashboard(cmd *cobra.Command, args []string) {
 			monthKey := statsState.Months[statsState.CurrentMonth]
 			currentMonthDisplay = formatMonthKey(monthKey)
 		}
+		filterLabel := ""
+		if len(statsState.Tags) > 0 {
+			filterLabel = fmt.Sprintf(" [#666666]│[white] [#55AAAA]%s[white]", formatTags(statsState.Tags))
+		}
 
 		header := tview.NewTextView().
 			SetTextAlign(tview.AlignCenter).
 			SetDynamicColors(true).
-			SetText(fmt.Sprintf("[::b][#00FFFF]Wallet Aggregator[white] [#666666]│[white] [#FF6600]Flow & Transactions[white]\n[#666666]◀[white] [::b]%s[:-] [#666666]▶[white]", currentMonthDisplay))
+			SetText(fmt.Sprintf("[::b][#00FFFF]Wallet Aggregator[white] [#666666]│[white] [#FF6600]Flow & Transactions[white]%s%s\n[#666666]◀[white] [::b]%s[:-] [#666666]▶[white]", profileLabel(), filterLabel, currentMonthDisplay))
 		header.SetBorder(true)
 		flex.AddItem(header, 4, 0, false)
 
@@ -201,7 +222,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 		footer := tview.NewTextView().
 			SetTextAlign(tview.AlignCenter).
 			SetDynamicColors(true).
-			SetText("[::b][#AAAAAA]Press [#FFFFFF]:[#AAAAAA] commands | [#FFFFFF]←/→[#AAAAAA] month | [#FFFFFF]s[#AAAAAA] balances | [#FFFFFF]r[#AAAAAA] reload")
+			SetText("[::b][#AAAAAA]Press [#FFFFFF]:[#AAAAAA] commands | [#FFFFFF]←/→[#AAAAAA] month | [#FFFFFF]:filter #tag[#AAAAAA] tags | [#FFFFFF]s[#AAAAAA] balances | [#FFFFFF]u[#AAAAAA]/[#FFFFFF]^R[#AAAAAA] undo/redo | [#FFFFFF]r[#AAAAAA] reload")
 		footer.SetBorder(false)
 		flex.AddItem(footer, 1, 0, false)
 
@@ -215,14 +236,42 @@ func showDashboard(cmd *cobra.Command, args []string) {
 		return
 	}
 	_ = util.UpdateCoinPrices(s, s.ListWallets())
+	s.Close()
+
+	// Switching to an encrypted profile asks for its passphrase on the plain
+	// terminal, with the TUI suspended meanwhile
+	storage.PassphrasePrompt = func(prompt string) (passphrase string, err error) {
+		if !app.Suspend(func() { passphrase, err = promptPassphrase(prompt) }) {
+			passphrase, err = promptPassphrase(prompt)
+		}
+		return passphrase, err
+	}
 
 	// buildDashboard creates the appropriate dashboard based on current view
 	buildDashboard := func() *tview.Flex {
-		// Reload storage data
-		s, _ = storage.New()
+		// Reload storage data, holding the lock only while reading so other
+		// wago processes can write while the dashboard is open
+		reloaded, err := storage.New()
+		if err != nil {
+			flex := tview.NewFlex().SetDirection(tview.FlexRow)
+			msg := tview.NewTextView().
+				SetTextAlign(tview.AlignCenter).
+				SetDynamicColors(true).
+				SetText(fmt.Sprintf("[red]Failed to load data:[white] %v\n\nPress [yellow]r[white] to retry", err))
+			flex.AddItem(msg, 0, 1, false)
+			return flex
+		}
+		reloaded.Close()
+		s = reloaded
 
-		// Get all wallets
+		// Get all wallets and categories; archived ones only take part in the
+		// stats view
 		wallets := s.ListWallets()
+		categories := s.ListCategories()
+		if currentView != ViewStats {
+			wallets = activeWallets(wallets, categories)
+			categories = activeCategories(categories)
+		}
 		if len(wallets) == 0 {
 			// Return a simple message view with help
 			flex := tview.NewFlex().SetDirection(tview.FlexRow)
@@ -234,9 +283,6 @@ func showDashboard(cmd *cobra.Command, args []string) {
 			return flex
 		}
 
-		// Get all categories
-		categories := s.ListCategories()
-
 		switch currentView {
 		case ViewStats:
 			return buildStatsDashboard(s, wallets, categories)
@@ -246,7 +292,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 	}
 
 	// Command palette
-	cmdPalette := NewCommandPalette(s)
+	cmdPalette := NewCommandPalette()
 
 	// Command input field
 	cmdInput := tview.NewInputField().
@@ -353,6 +399,18 @@ func showDashboard(cmd *cobra.Command, args []string) {
 
 				setStatus(result.Message, !result.Success)
 
+				// A different ledger has its own wallet list
+				if result.Switched {
+					mainState.SelectedWallet = 0
+					statsState.Tags = nil
+				}
+
+				// Filtering only applies to the stats view
+				if result.Filtered {
+					statsState.Tags = result.Filter
+					currentView = ViewStats
+				}
+
 				// Reload dashboard
 				statsState.Months = nil
 				app.SetRoot(buildFullUI(), true)
@@ -403,6 +461,18 @@ func showDashboard(cmd *cobra.Command, args []string) {
 			app.SetRoot(buildFullUI(), true)
 			return nil
 		}
+		// Undo/redo the last change
+		if event.Rune() == 'u' || event.Key() == tcell.KeyCtrlR {
+			command := "undo"
+			if event.Key() == tcell.KeyCtrlR {
+				command = "redo"
+			}
+			result := cmdPalette.Execute(command)
+			setStatus(result.Message, !result.Success)
+			statsState.Months = nil
+			app.SetRoot(buildFullUI(), true)
+			return nil
+		}
 		if event.Rune() == 's' {
 			if currentView == ViewMain {
 				currentView = ViewStats
@@ -433,7 +503,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 
 		// Main view: up/down for wallet selection
 		if currentView == ViewMain {
-			walletCount := len(s.ListWallets())
+			walletCount := len(activeWallets(s.ListWallets(), s.ListCategories()))
 			if event.Key() == tcell.KeyUp {
 				if mainState.SelectedWallet > 0 {
 					mainState.SelectedWallet--
@@ -450,7 +520,7 @@ func showDashboard(cmd *cobra.Command, args []string) {
 			}
 			// Enter to copy wallet address
 			if event.Key() == tcell.KeyEnter {
-				wallets := s.ListWallets()
+				wallets := activeWallets(s.ListWallets(), s.ListCategories())
 				sort.Slice(wallets, func(i, j int) bool {
 					if wallets[i].Category != wallets[j].Category {
 						return wallets[i].Category < wallets[j].Category
@@ -479,6 +549,15 @@ func showDashboard(cmd *cobra.Command, args []string) {
 	}
 }
 
+// profileLabel returns the header suffix naming the active profile, if any
+func profileLabel() string {
+	profile := storage.CurrentProfile()
+	if profile == storage.DefaultProfile {
+		return ""
+	}
+	return fmt.Sprintf(" [#666666]│[white] [#FFFF00]%s[white]", profile)
+}
+
 // groupTransactionsByMonth groups transactions by year-month
 func groupTransactionsByMonth(txs []*model.Tx) map[string][]*model.Tx {
 	result := make(map[string][]*model.Tx)
@@ -521,15 +600,20 @@ type FlowEdge struct {
 	From   string
 	To     string
 	Coin   string
-	Amount float64
+	Amount decimal.Decimal
 	Count  int
 	Dates  []time.Time
 	TxType model.TxType
 	// For swaps
 	SellCoin   string
-	SellAmount float64
+	SellAmount decimal.Decimal
 	BuyCoin    string
-	BuyAmount  float64
+	BuyAmount  decimal.Decimal
+	// For bridges
+	FromChain      string
+	ToChain        string
+	ReceivedCoin   string
+	ReceivedAmount decimal.Decimal
 }
 
 // createFlowCanvas creates the flow visualization for a month's transactions
@@ -560,7 +644,9 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 	}
 
 	edges := make(map[string]*FlowEdge)
-	swaps := []*FlowEdge{} // Keep swaps separate
+	swaps := []*FlowEdge{}  // Keep swaps separate
+	multis := []*model.Tx{} // Multi-leg transactions are listed whole
+	pools := make(map[string]bool)
 
 	for _, tx := range txs {
 		switch tx.Type {
@@ -569,7 +655,7 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			to := tx.ToWallet
 			key := edgeKey(from, to, tx.Coin, tx.Type)
 			if e, exists := edges[key]; exists {
-				e.Amount += tx.Amount
+				e.Amount = e.Amount.Add(tx.Amount)
 				e.Count++
 				e.Dates = append(e.Dates, tx.Date)
 			} else {
@@ -589,7 +675,7 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			to := "External"
 			key := edgeKey(from, to, tx.Coin, tx.Type)
 			if e, exists := edges[key]; exists {
-				e.Amount += tx.Amount
+				e.Amount = e.Amount.Add(tx.Amount)
 				e.Count++
 				e.Dates = append(e.Dates, tx.Date)
 			} else {
@@ -622,7 +708,34 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			}
 			key := edgeKey(from, to, tx.Coin, tx.Type)
 			if e, exists := edges[key]; exists {
-				e.Amount += tx.Amount
+				e.Amount = e.Amount.Add(tx.Amount)
+				e.Count++
+				e.Dates = append(e.Dates, tx.Date)
+			} else {
+				edges[key] = &FlowEdge{
+					From:   from,
+					To:     to,
+					Coin:   tx.Coin,
+					Amount: tx.Amount,
+					Count:  1,
+					Dates:  []time.Time{tx.Date},
+					TxType: tx.Type,
+				}
+			}
+
+		case model.TxTypeStake, model.TxTypeUnstake,
+			model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+			// Staking moves funds to a pseudo node, earnings come from outside
+			from, to := "External", tx.ToWallet
+			switch tx.Type {
+			case model.TxTypeStake:
+				from, to = tx.FromWallet, "Staked"
+			case model.TxTypeUnstake:
+				from, to = tx.FromWallet, "Unstaked"
+			}
+			key := edgeKey(from, to, tx.Coin, tx.Type)
+			if e, exists := edges[key]; exists {
+				e.Amount = e.Amount.Add(tx.Amount)
 				e.Count++
 				e.Dates = append(e.Dates, tx.Date)
 			} else {
@@ -637,6 +750,66 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 				}
 			}
 
+		case model.TxTypeBridge:
+			// Kept apart per chain pair and received token
+			received, receivedAmount := storage.BridgeReceived(tx)
+			key := edgeKey(tx.FromWallet, tx.ToWallet, tx.Coin+">"+received+"|"+tx.FromChain+">"+tx.ToChain, tx.Type)
+			if e, exists := edges[key]; exists {
+				e.Amount = e.Amount.Add(tx.Amount)
+				e.ReceivedAmount = e.ReceivedAmount.Add(receivedAmount)
+				e.Count++
+				e.Dates = append(e.Dates, tx.Date)
+			} else {
+				edges[key] = &FlowEdge{
+					From:           tx.FromWallet,
+					To:             tx.ToWallet,
+					Coin:           tx.Coin,
+					Amount:         tx.Amount,
+					Count:          1,
+					Dates:          []time.Time{tx.Date},
+					TxType:         tx.Type,
+					FromChain:      tx.FromChain,
+					ToChain:        tx.ToChain,
+					ReceivedCoin:   received,
+					ReceivedAmount: receivedAmount,
+				}
+			}
+
+		case model.TxTypeLPAdd, model.TxTypeLPRemove:
+			// Both coins flow between the wallet and the pool
+			pools[tx.Pool] = true
+			from, to := tx.FromWallet, tx.Pool
+			if tx.Type == model.TxTypeLPRemove {
+				from, to = tx.Pool, tx.FromWallet
+			}
+			for _, side := range []struct {
+				coin   string
+				amount decimal.Decimal
+			}{{tx.Coin, tx.Amount}, {tx.PairCoin, tx.PairAmount}} {
+				if side.amount.IsZero() {
+					continue
+				}
+				key := edgeKey(from, to, side.coin, tx.Type)
+				if e, exists := edges[key]; exists {
+					e.Amount = e.Amount.Add(side.amount)
+					e.Count++
+					e.Dates = append(e.Dates, tx.Date)
+				} else {
+					edges[key] = &FlowEdge{
+						From:   from,
+						To:     to,
+						Coin:   side.coin,
+						Amount: side.amount,
+						Count:  1,
+						Dates:  []time.Time{tx.Date},
+						TxType: tx.Type,
+					}
+				}
+			}
+
+		case model.TxTypeMulti:
+			multis = append(multis, tx)
+
 		case model.TxTypeSwap:
 			swaps = append(swaps, &FlowEdge{
 				From:       tx.SwapWallet,
@@ -692,6 +865,9 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			return "[#888888]External[white]"
 		}
 		addr := addrSnippet(name)
+		if pools[name] {
+			return fmt.Sprintf("[#FF77FF]%s[white]", name)
+		}
 		if walletNames[name] {
 			return fmt.Sprintf("[#00FFFF]%s[white] %s", name, addr)
 		}
@@ -701,8 +877,12 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 
 	// Helper to render a target node (for arrow destination)
 	renderTargetNode := func(name string) string {
-		if name == "External" {
-			return "[#888888]External[white]"
+		switch name {
+		case "External", "Staked", "Unstaked":
+			return fmt.Sprintf("[#888888]%s[white]", name)
+		}
+		if pools[name] {
+			return fmt.Sprintf("[#FF77FF]%s[white]", name)
 		}
 		if walletNames[name] {
 			return fmt.Sprintf("[#00FFFF]%s[white]", name)
@@ -740,7 +920,7 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 	}
 
 	for _, edge := range allEdges {
-		amountStr := fmt.Sprintf("%.2f", edge.Amount)
+		amountStr := edge.Amount.StringFixed(2)
 		if len(amountStr) > maxAmountLen {
 			maxAmountLen = len(amountStr)
 		}
@@ -792,6 +972,16 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 				arrowColor = "#FF5555"
 			case model.TxTypeTransfer:
 				arrowColor = "#FFFF00"
+			case model.TxTypeStake, model.TxTypeUnstake:
+				arrowColor = "#00CCCC"
+			case model.TxTypeReward:
+				arrowColor = "#55FF99"
+			case model.TxTypeAirdrop:
+				arrowColor = "#00FFCC"
+			case model.TxTypeIncome:
+				arrowColor = "#5599FF"
+			case model.TxTypeLPAdd, model.TxTypeLPRemove:
+				arrowColor = "#FF77FF"
 			}
 
 			// Tree branch character
@@ -801,7 +991,7 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			}
 
 			// Format each part with padding
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, edge.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, edge.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, edge.Coin)
 
 			countStr := ""
@@ -823,8 +1013,19 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			// Arrow length adjusts based on count field usage
 			arrow := "──>"
 
-			content.WriteString(fmt.Sprintf("    %s [%s]%s %s %s %s[white] %s   [#666666]%s[white]\n",
-				branch, arrowColor, amountStr, coinStr, countPadded, arrow, target, dateLabel))
+			// Bridges hop chains: a wavy arrow and what arrived on the other side
+			bridgeInfo := ""
+			if edge.TxType == model.TxTypeBridge {
+				arrowColor = "#FF9900"
+				arrow = "~~>"
+				bridgeInfo = fmt.Sprintf("  [#FF9900]%s → %s[white]", edge.FromChain, edge.ToChain)
+				if edge.ReceivedCoin != edge.Coin || !edge.ReceivedAmount.Equal(edge.Amount) {
+					bridgeInfo += fmt.Sprintf(" [#666666]received %s %s[white]", edge.ReceivedAmount.StringFixed(2), edge.ReceivedCoin)
+				}
+			}
+
+			content.WriteString(fmt.Sprintf("    %s [%s]%s %s %s %s[white] %s   [#666666]%s[white]%s\n",
+				branch, arrowColor, amountStr, coinStr, countPadded, arrow, target, dateLabel, bridgeInfo))
 		}
 		content.WriteString("\n")
 	}
@@ -862,24 +1063,82 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 			// Render individual swaps
 			for _, swap := range group {
 				dateStr := fmt.Sprintf("[#666666]%s[white]", formatDates(swap.Dates))
-				content.WriteString(fmt.Sprintf("  %s %s  [#FF00FF]%.2f %s  ⇄  %.2f %s[white]  %s\n",
+				content.WriteString(fmt.Sprintf("  %s %s  [#FF00FF]%s %s  ⇄  %s %s[white]  %s\n",
 					walletDisplay, walletAddr,
-					swap.SellAmount, swap.SellCoin,
-					swap.BuyAmount, swap.BuyCoin,
+					swap.SellAmount.StringFixed(2), swap.SellCoin,
+					swap.BuyAmount.StringFixed(2), swap.BuyCoin,
 					dateStr))
 			}
 
 			// Show total if 2+ swaps in this group
 			if len(group) >= 2 {
-				var totalSell, totalBuy float64
+				totalSell, totalBuy := decimal.Zero, decimal.Zero
 				for _, swap := range group {
-					totalSell += swap.SellAmount
-					totalBuy += swap.BuyAmount
+					totalSell = totalSell.Add(swap.SellAmount)
+					totalBuy = totalBuy.Add(swap.BuyAmount)
 				}
 				// Pad to align with the amounts column
 				padding := strings.Repeat(" ", prefixLen)
-				content.WriteString(fmt.Sprintf("%s[#FF00FF][::b]Σ %.2f %s  ⇄  %.2f %s[:-][white]\n",
-					padding, totalSell, key.sellCoin, totalBuy, key.buyCoin))
+				content.WriteString(fmt.Sprintf("%s[#FF00FF][::b]Σ %s %s  ⇄  %s %s[:-][white]\n",
+					padding, totalSell.StringFixed(2), key.sellCoin, totalBuy.StringFixed(2), key.buyCoin))
+			}
+		}
+	}
+
+	// Render multi-leg transactions with their legs grouped under the ID
+	if len(multis) > 0 {
+		content.WriteString("\n[::b]Multi-leg:[:-]\n")
+		sorted := make([]*model.Tx, len(multis))
+		copy(sorted, multis)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].Date.Before(sorted[j].Date)
+		})
+		for _, tx := range sorted {
+			line := fmt.Sprintf("  [#8888FF]≡[white] %s  [#666666]%s[white]", tx.ID, tx.Date.Format("Jan 02"))
+			if tx.Note != "" {
+				line += fmt.Sprintf("  [#666666]// %s[white]", tx.Note)
+			}
+			line += renderTags(tx.Tags)
+			content.WriteString(line + "\n")
+			for i, leg := range tx.Legs {
+				branch := "├──"
+				if i == len(tx.Legs)-1 {
+					branch = "└──"
+				}
+				content.WriteString(fmt.Sprintf("    %s %s\n", branch, renderLeg(leg, renderTargetNode(leg.Wallet))))
+			}
+		}
+	}
+
+	// Render the fees paid this month per wallet, with a total per coin
+	if fees := sumFees(txs); len(fees) > 0 {
+		content.WriteString("\n[::b]Fees:[:-]\n")
+		payers := make([]string, 0, len(fees))
+		for wallet := range fees {
+			payers = append(payers, wallet)
+		}
+		sort.Strings(payers)
+
+		totals := make(map[string]decimal.Decimal)
+		for _, wallet := range payers {
+			coins := make([]string, 0, len(fees[wallet]))
+			for coin, fee := range fees[wallet] {
+				coins = append(coins, coin)
+				totals[coin] = totals[coin].Add(fee)
+			}
+			sort.Strings(coins)
+			for _, coin := range coins {
+				content.WriteString(fmt.Sprintf("  %s  [#FF5555]%s %s[white]\n", renderTargetNode(wallet), fees[wallet][coin].String(), coin))
+			}
+		}
+		if len(payers) >= 2 {
+			coins := make([]string, 0, len(totals))
+			for coin := range totals {
+				coins = append(coins, coin)
+			}
+			sort.Strings(coins)
+			for _, coin := range coins {
+				content.WriteString(fmt.Sprintf("  [#FF5555][::b]Σ %s %s[:-][white]\n", totals[coin].String(), coin))
 			}
 		}
 	}
@@ -889,7 +1148,7 @@ func createFlowCanvas(txs []*model.Tx, wallets []*model.Wallet) *tview.TextView
 }
 
 // collectAllTransactions gathers all transactions from storage
-func collectAllTransactions(s *storage.Storage) []*model.Tx {
+func collectAllTransactions(s storage.Store) []*model.Tx {
 	allTxs := s.ListTransactions()
 
 	// Sort by date (newest first)
@@ -1037,8 +1296,9 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 
 	for _, tx := range sortedTxs {
 		switch tx.Type {
-		case model.TxTypeDeposit, model.TxTypeWithdraw:
-			amountStr := fmt.Sprintf("%.2f", tx.Amount)
+		case model.TxTypeDeposit, model.TxTypeWithdraw, model.TxTypeStake, model.TxTypeUnstake,
+			model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome, model.TxTypeLPAdd, model.TxTypeLPRemove:
+			amountStr := tx.Amount.StringFixed(2)
 			if len(amountStr) > maxAmountLen {
 				maxAmountLen = len(amountStr)
 			}
@@ -1051,8 +1311,8 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 			if len(tx.FromWallet) > maxFromLen {
 				maxFromLen = len(tx.FromWallet)
 			}
-		case model.TxTypeTransfer:
-			amountStr := fmt.Sprintf("%.2f", tx.Amount)
+		case model.TxTypeTransfer, model.TxTypeBridge:
+			amountStr := tx.Amount.StringFixed(2)
 			if len(amountStr) > maxAmountLen {
 				maxAmountLen = len(amountStr)
 			}
@@ -1076,14 +1336,14 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 			if len(tx.SwapWallet) > maxSwapWalletLen {
 				maxSwapWalletLen = len(tx.SwapWallet)
 			}
-			sellAmountStr := fmt.Sprintf("%.2f", tx.SellAmount)
+			sellAmountStr := tx.SellAmount.StringFixed(2)
 			if len(sellAmountStr) > maxSellAmountLen {
 				maxSellAmountLen = len(sellAmountStr)
 			}
 			if len(tx.SellCoin) > maxSellCoinLen {
 				maxSellCoinLen = len(tx.SellCoin)
 			}
-			buyAmountStr := fmt.Sprintf("%.2f", tx.BuyAmount)
+			buyAmountStr := tx.BuyAmount.StringFixed(2)
 			if len(buyAmountStr) > maxBuyAmountLen {
 				maxBuyAmountLen = len(buyAmountStr)
 			}
@@ -1105,14 +1365,14 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 		case model.TxTypeDeposit:
 			typeIcon = "▼"
 			typeColor = "#00FF00"
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, tx.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
 			toStr := fmt.Sprintf("%-*s", maxToLen, tx.ToWallet)
 			details = fmt.Sprintf("%s %s  →  %s", amountStr, coinStr, toStr)
 		case model.TxTypeWithdraw:
 			typeIcon = "▲"
 			typeColor = "#FF5555"
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, tx.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
 			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
 			details = fmt.Sprintf("%s %s  ←  %s", amountStr, coinStr, fromStr)
@@ -1126,7 +1386,7 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 					toWallet = toWallet[:6] + "..." + toWallet[len(toWallet)-4:]
 				}
 			}
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, tx.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
 			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
 			toStr := fmt.Sprintf("%-*s", maxToLen, toWallet)
@@ -1135,11 +1395,59 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 			typeIcon = "⇄"
 			typeColor = "#FF00FF"
 			walletStr := fmt.Sprintf("%-*s", maxSwapWalletLen, tx.SwapWallet)
-			sellAmountStr := fmt.Sprintf("%*.2f", maxSellAmountLen, tx.SellAmount)
+			sellAmountStr := fmt.Sprintf("%*s", maxSellAmountLen, tx.SellAmount.StringFixed(2))
 			sellCoinStr := fmt.Sprintf("%-*s", maxSellCoinLen, tx.SellCoin)
-			buyAmountStr := fmt.Sprintf("%*.2f", maxBuyAmountLen, tx.BuyAmount)
+			buyAmountStr := fmt.Sprintf("%*s", maxBuyAmountLen, tx.BuyAmount.StringFixed(2))
 			buyCoinStr := fmt.Sprintf("%-*s", maxBuyCoinLen, tx.BuyCoin)
 			details = fmt.Sprintf("%s  %s %s  →  %s %s", walletStr, sellAmountStr, sellCoinStr, buyAmountStr, buyCoinStr)
+		case model.TxTypeStake, model.TxTypeUnstake:
+			typeIcon = "⇣"
+			direction := "→  staked"
+			if tx.Type == model.TxTypeUnstake {
+				typeIcon = "⇡"
+				direction = "←  staked"
+			}
+			typeColor = "#00CCCC"
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
+			details = fmt.Sprintf("%s %s  %s  %s", amountStr, coinStr, fromStr, direction)
+		case model.TxTypeBridge:
+			typeIcon = "⇝"
+			typeColor = "#FF9900"
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
+			toStr := fmt.Sprintf("%-*s", maxToLen, tx.ToWallet)
+			details = fmt.Sprintf("%s %s  %s  ⇝  %s", amountStr, coinStr, fromStr, toStr)
+		case model.TxTypeLPAdd, model.TxTypeLPRemove:
+			typeIcon = "⊕"
+			arrow := "→"
+			if tx.Type == model.TxTypeLPRemove {
+				typeIcon, arrow = "⊖", "←"
+			}
+			typeColor = "#FF77FF"
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
+			details = fmt.Sprintf("%s %s  %s  %s  %s (+ %s %s)", amountStr, coinStr, fromStr, arrow, tx.Pool, tx.PairAmount.StringFixed(2), tx.PairCoin)
+		case model.TxTypeMulti:
+			typeIcon = "≡"
+			typeColor = "#8888FF"
+			details = fmt.Sprintf("%s  %d legs", tx.ID, len(tx.Legs))
+		case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+			switch tx.Type {
+			case model.TxTypeReward:
+				typeIcon, typeColor = "★", "#55FF99"
+			case model.TxTypeAirdrop:
+				typeIcon, typeColor = "◆", "#00FFCC"
+			default:
+				typeIcon, typeColor = "$", "#5599FF"
+			}
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			toStr := fmt.Sprintf("%-*s", maxToLen, tx.ToWallet)
+			details = fmt.Sprintf("%s %s  →  %s", amountStr, coinStr, toStr)
 		}
 
 		line := fmt.Sprintf("[#666666]%s[white] [%s]%s[white] %s", dateStr, typeColor, typeIcon, details)
@@ -1148,14 +1456,38 @@ func createTransactionsView(txs []*model.Tx) *tview.TextView {
 		if tx.Note != "" {
 			line += fmt.Sprintf("  [#666666]// %s[white]", tx.Note)
 		}
+		line += renderTags(tx.Tags)
 
 		content.WriteString(line + "\n")
+
+		// Legs go under their transaction
+		for _, leg := range tx.Legs {
+			content.WriteString(fmt.Sprintf("         %s\n", renderLeg(leg, leg.Wallet)))
+		}
 	}
 
 	view.SetText(content.String())
 	return view
 }
 
+// renderLeg formats a leg of a multi-leg transaction for tview, with wallet
+// already rendered as the caller wants it
+func renderLeg(leg model.Leg, wallet string) string {
+	amountColor, sign := "#00FF00", "+"
+	if leg.Amount.IsNegative() {
+		amountColor, sign = "#FF5555", ""
+	}
+	return fmt.Sprintf("[%s]%s%s %s[white]  %s", amountColor, sign, leg.Amount.StringFixed(2), leg.Coin, wallet)
+}
+
+// renderTags formats the tags of a transaction for tview, or "" without any
+func renderTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  [#55AAAA]%s[white]", formatTags(tags))
+}
+
 // createWalletsPanel creates the wallets list panel with selection highlighting
 func createWalletsPanel(wallets []*model.Wallet, categories []*model.Category, selectedIdx int) *tview.TextView {
 	view := tview.NewTextView().
@@ -1220,8 +1552,9 @@ func createWalletsPanel(wallets []*model.Wallet, categories []*model.Category, s
 	return view
 }
 
-// createWalletBalancesPanel creates the balances panel for selected wallet
-func createWalletBalancesPanel(wallet *model.Wallet) *tview.TextView {
+// createWalletBalancesPanel creates the balances panel for selected wallet,
+// followed by its liquidity positions
+func createWalletBalancesPanel(wallet *model.Wallet, positions []*model.Position) *tview.TextView {
 	view := tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true)
@@ -1233,7 +1566,7 @@ func createWalletBalancesPanel(wallet *model.Wallet) *tview.TextView {
 		return view
 	}
 
-	if len(wallet.Balances) == 0 {
+	if len(wallet.Balances) == 0 && len(positions) == 0 {
 		view.SetText("[#AAAAAA]No balances[white]")
 		return view
 	}
@@ -1241,10 +1574,13 @@ func createWalletBalancesPanel(wallet *model.Wallet) *tview.TextView {
 	// Get coins for price fetching
 	coins := make([]string, 0, len(wallet.Balances))
 	for _, bal := range wallet.Balances {
-		if bal.Amount > 0 {
+		if bal.Amount.IsPositive() {
 			coins = append(coins, bal.Coin)
 		}
 	}
+	for _, pos := range positions {
+		coins = append(coins, pos.Coin, pos.PairCoin)
+	}
 	sort.Strings(coins)
 
 	prices, _ := util.GetCoinPrices(coins)
@@ -1252,19 +1588,32 @@ func createWalletBalancesPanel(wallet *model.Wallet) *tview.TextView {
 	// Same format as Total Balance by Coin: COIN: amount (usd)
 	var content strings.Builder
 	for _, bal := range wallet.Balances {
-		if bal.Amount == 0 {
+		if bal.Amount.IsZero() {
 			continue
 		}
 		if prices != nil {
 			if price, exists := prices[strings.ToLower(bal.Coin)]; exists {
-				usdValue := bal.Amount * price
-				content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%.2f[white] [#AAAAAA](%s)[white]\n",
-					bal.Coin, bal.Amount, util.FormatUSDValue(usdValue)))
+				usdValue := util.USDValue(bal.Amount, price)
+				content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%s[white] [#AAAAAA](%s)[white]\n",
+					bal.Label(), bal.Amount.StringFixed(2), util.FormatUSDValue(usdValue)))
 			} else {
-				content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%.2f[white]\n", bal.Coin, bal.Amount))
+				content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%s[white]\n", bal.Label(), bal.Amount.StringFixed(2)))
 			}
 		} else {
-			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%.2f[white]\n", bal.Coin, bal.Amount))
+			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%s[white]\n", bal.Label(), bal.Amount.StringFixed(2)))
+		}
+	}
+
+	// Positions are valued from their underlying assets
+	if len(positions) > 0 {
+		content.WriteString("\n[::b]Liquidity:[:-]\n")
+		for _, pos := range positions {
+			content.WriteString(fmt.Sprintf("[#FF77FF]%s[white]  %s LP\n", pos.Pool, pos.LPTokens))
+			content.WriteString(fmt.Sprintf("  %s %s + %s %s", pos.Amount.StringFixed(2), pos.Coin, pos.PairAmount.StringFixed(2), pos.PairCoin))
+			if value, priced := storage.PositionValue(pos, prices); priced {
+				content.WriteString(fmt.Sprintf(" [#AAAAAA](%s)[white]", util.FormatUSDValue(value)))
+			}
+			content.WriteString("\n")
 		}
 	}
 
@@ -1306,8 +1655,9 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 
 	for _, tx := range sortedTxs {
 		switch tx.Type {
-		case model.TxTypeDeposit, model.TxTypeWithdraw:
-			amountStr := fmt.Sprintf("%.2f", tx.Amount)
+		case model.TxTypeDeposit, model.TxTypeWithdraw, model.TxTypeStake, model.TxTypeUnstake,
+			model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome, model.TxTypeLPAdd, model.TxTypeLPRemove:
+			amountStr := tx.Amount.StringFixed(2)
 			if len(amountStr) > maxAmountLen {
 				maxAmountLen = len(amountStr)
 			}
@@ -1320,8 +1670,8 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 			if len(tx.FromWallet) > maxFromLen {
 				maxFromLen = len(tx.FromWallet)
 			}
-		case model.TxTypeTransfer:
-			amountStr := fmt.Sprintf("%.2f", tx.Amount)
+		case model.TxTypeTransfer, model.TxTypeBridge:
+			amountStr := tx.Amount.StringFixed(2)
 			if len(amountStr) > maxAmountLen {
 				maxAmountLen = len(amountStr)
 			}
@@ -1345,14 +1695,14 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 			if len(tx.SwapWallet) > maxSwapWalletLen {
 				maxSwapWalletLen = len(tx.SwapWallet)
 			}
-			sellAmountStr := fmt.Sprintf("%.2f", tx.SellAmount)
+			sellAmountStr := tx.SellAmount.StringFixed(2)
 			if len(sellAmountStr) > maxSellAmountLen {
 				maxSellAmountLen = len(sellAmountStr)
 			}
 			if len(tx.SellCoin) > maxSellCoinLen {
 				maxSellCoinLen = len(tx.SellCoin)
 			}
-			buyAmountStr := fmt.Sprintf("%.2f", tx.BuyAmount)
+			buyAmountStr := tx.BuyAmount.StringFixed(2)
 			if len(buyAmountStr) > maxBuyAmountLen {
 				maxBuyAmountLen = len(buyAmountStr)
 			}
@@ -1372,14 +1722,14 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 		case model.TxTypeDeposit:
 			typeIcon = "▼"
 			typeColor = "#00FF00"
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, tx.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
 			toStr := fmt.Sprintf("%-*s", maxToLen, tx.ToWallet)
 			details = fmt.Sprintf("%s %s  →  %s", amountStr, coinStr, toStr)
 		case model.TxTypeWithdraw:
 			typeIcon = "▲"
 			typeColor = "#FF5555"
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, tx.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
 			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
 			details = fmt.Sprintf("%s %s  ←  %s", amountStr, coinStr, fromStr)
@@ -1393,7 +1743,7 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 					toWallet = toWallet[:6] + "..." + toWallet[len(toWallet)-4:]
 				}
 			}
-			amountStr := fmt.Sprintf("%*.2f", maxAmountLen, tx.Amount)
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
 			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
 			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
 			toStr := fmt.Sprintf("%-*s", maxToLen, toWallet)
@@ -1402,11 +1752,59 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 			typeIcon = "⇄"
 			typeColor = "#FF00FF"
 			walletStr := fmt.Sprintf("%-*s", maxSwapWalletLen, tx.SwapWallet)
-			sellAmountStr := fmt.Sprintf("%*.2f", maxSellAmountLen, tx.SellAmount)
+			sellAmountStr := fmt.Sprintf("%*s", maxSellAmountLen, tx.SellAmount.StringFixed(2))
 			sellCoinStr := fmt.Sprintf("%-*s", maxSellCoinLen, tx.SellCoin)
-			buyAmountStr := fmt.Sprintf("%*.2f", maxBuyAmountLen, tx.BuyAmount)
+			buyAmountStr := fmt.Sprintf("%*s", maxBuyAmountLen, tx.BuyAmount.StringFixed(2))
 			buyCoinStr := fmt.Sprintf("%-*s", maxBuyCoinLen, tx.BuyCoin)
 			details = fmt.Sprintf("%s  %s %s  →  %s %s", walletStr, sellAmountStr, sellCoinStr, buyAmountStr, buyCoinStr)
+		case model.TxTypeStake, model.TxTypeUnstake:
+			typeIcon = "⇣"
+			direction := "→  staked"
+			if tx.Type == model.TxTypeUnstake {
+				typeIcon = "⇡"
+				direction = "←  staked"
+			}
+			typeColor = "#00CCCC"
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
+			details = fmt.Sprintf("%s %s  %s  %s", amountStr, coinStr, fromStr, direction)
+		case model.TxTypeBridge:
+			typeIcon = "⇝"
+			typeColor = "#FF9900"
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
+			toStr := fmt.Sprintf("%-*s", maxToLen, tx.ToWallet)
+			details = fmt.Sprintf("%s %s  %s  ⇝  %s", amountStr, coinStr, fromStr, toStr)
+		case model.TxTypeLPAdd, model.TxTypeLPRemove:
+			typeIcon = "⊕"
+			arrow := "→"
+			if tx.Type == model.TxTypeLPRemove {
+				typeIcon, arrow = "⊖", "←"
+			}
+			typeColor = "#FF77FF"
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			fromStr := fmt.Sprintf("%-*s", maxFromLen, tx.FromWallet)
+			details = fmt.Sprintf("%s %s  %s  %s  %s (+ %s %s)", amountStr, coinStr, fromStr, arrow, tx.Pool, tx.PairAmount.StringFixed(2), tx.PairCoin)
+		case model.TxTypeMulti:
+			typeIcon = "≡"
+			typeColor = "#8888FF"
+			details = fmt.Sprintf("%s  %d legs", tx.ID, len(tx.Legs))
+		case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+			switch tx.Type {
+			case model.TxTypeReward:
+				typeIcon, typeColor = "★", "#55FF99"
+			case model.TxTypeAirdrop:
+				typeIcon, typeColor = "◆", "#00FFCC"
+			default:
+				typeIcon, typeColor = "$", "#5599FF"
+			}
+			amountStr := fmt.Sprintf("%*s", maxAmountLen, tx.Amount.StringFixed(2))
+			coinStr := fmt.Sprintf("%-*s", maxCoinLen, tx.Coin)
+			toStr := fmt.Sprintf("%-*s", maxToLen, tx.ToWallet)
+			details = fmt.Sprintf("%s %s  →  %s", amountStr, coinStr, toStr)
 		}
 
 		line := fmt.Sprintf("[#666666]%s[white] [%s]%s[white] %s", dateStr, typeColor, typeIcon, details)
@@ -1414,16 +1812,39 @@ func createWalletTransactionsPanel(txs []*model.Tx) *tview.TextView {
 		if tx.Note != "" {
 			line += fmt.Sprintf("  [#666666]// %s[white]", tx.Note)
 		}
+		line += renderTags(tx.Tags)
 
 		content.WriteString(line + "\n")
+
+		// Legs go under their transaction
+		for _, leg := range tx.Legs {
+			content.WriteString(fmt.Sprintf("         %s\n", renderLeg(leg, leg.Wallet)))
+		}
 	}
 
 	view.SetText(content.String())
 	return view
 }
 
-// createTotalBalanceView creates a view showing total balance by coin
-func createTotalBalanceView(wallets []*model.Wallet) *tview.TextView {
+// walletPositions returns the open LP positions held by the given wallets
+func walletPositions(s storage.Store, wallets []*model.Wallet) []*model.Position {
+	shown := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		shown[wallet.Name] = true
+	}
+
+	var positions []*model.Position
+	for _, pos := range s.ListPositions("") {
+		if shown[pos.Wallet] {
+			positions = append(positions, pos)
+		}
+	}
+	return positions
+}
+
+// createTotalBalanceView creates a view showing total balance by coin and
+// the value of open LP positions
+func createTotalBalanceView(wallets []*model.Wallet, positions []*model.Position) *tview.TextView {
 	view := tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true)
@@ -1431,30 +1852,39 @@ func createTotalBalanceView(wallets []*model.Wallet) *tview.TextView {
 	view.SetBorder(true).SetTitle(" Total Balance by Coin ")
 
 	// Calculate total balance by coin
-	balanceByCoin := make(map[string]float64)
+	balanceByCoin := make(map[string]decimal.Decimal)
 	for _, wallet := range wallets {
 		for _, balance := range wallet.Balances {
-			balanceByCoin[balance.Coin] += balance.Amount
+			balanceByCoin[balance.Coin] = balanceByCoin[balance.Coin].Add(balance.Amount)
 		}
 	}
 
 	// Get all coin symbols for price fetching (skip zero balances)
 	coins := make([]string, 0, len(balanceByCoin))
 	for coin, amount := range balanceByCoin {
-		if amount > 0 {
+		if amount.IsPositive() {
 			coins = append(coins, coin)
 		}
 	}
 	sort.Strings(coins)
 
+	// Positions are priced from their underlying coins
+	priceCoins := append([]string{}, coins...)
+	for _, pos := range positions {
+		priceCoins = append(priceCoins, pos.Coin, pos.PairCoin)
+	}
+
 	// Fetch USD prices from manual prices.json
-	prices, err := util.GetCoinPrices(coins)
+	prices, err := util.GetCoinPrices(priceCoins)
 	if err != nil {
 		// If price fetching fails, show without USD values
 		var content strings.Builder
 		for _, coin := range coins {
 			balance := balanceByCoin[coin]
-			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%.2f[white]\n", coin, balance))
+			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%s[white]\n", coin, balance.StringFixed(2)))
+		}
+		for _, pos := range positions {
+			content.WriteString(fmt.Sprintf("[#FF77FF]%s[white]  %s LP\n", pos.Pool, pos.LPTokens))
 		}
 		view.SetText(content.String())
 		return view
@@ -1462,9 +1892,9 @@ func createTotalBalanceView(wallets []*model.Wallet) *tview.TextView {
 
 	// Calculate total net worth and format display
 	var content strings.Builder
-	totalNetWorth := 0.0
-	liquidNetWorth := 0.0
-	nonLiquidNetWorth := 0.0
+	totalNetWorth := decimal.Zero
+	liquidNetWorth := decimal.Zero
+	nonLiquidNetWorth := decimal.Zero
 
 	// Define stablecoins (liquid assets)
 	stablecoins := map[string]bool{
@@ -1481,25 +1911,45 @@ func createTotalBalanceView(wallets []*model.Wallet) *tview.TextView {
 	for _, coin := range coins {
 		balance := balanceByCoin[coin]
 		if price, exists := prices[strings.ToLower(coin)]; exists {
-			usdValue := balance * price
-			totalNetWorth += usdValue
+			usdValue := util.USDValue(balance, price)
+			totalNetWorth = totalNetWorth.Add(usdValue)
 
 			// Categorize as liquid or non-liquid
 			if stablecoins[strings.ToLower(coin)] {
-				liquidNetWorth += usdValue
+				liquidNetWorth = liquidNetWorth.Add(usdValue)
 			} else {
-				nonLiquidNetWorth += usdValue
+				nonLiquidNetWorth = nonLiquidNetWorth.Add(usdValue)
 			}
 
-			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%.2f[white] [#AAAAAA](%s)[white]\n",
-				coin, balance, util.FormatUSDValue(usdValue)))
+			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%s[white] [#AAAAAA](%s)[white]\n",
+				coin, balance.StringFixed(2), util.FormatUSDValue(usdValue)))
 		} else {
-			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%.2f[white]\n", coin, balance))
+			content.WriteString(fmt.Sprintf("[::b]%s:[:-]  [#00FF00]%s[white]\n", coin, balance.StringFixed(2)))
+		}
+	}
+
+	// LP positions count as stables only if both sides are
+	if len(positions) > 0 {
+		content.WriteString("\n[::b]Liquidity:[:-]\n")
+		for _, pos := range positions {
+			value, priced := storage.PositionValue(pos, prices)
+			if !priced {
+				content.WriteString(fmt.Sprintf("[#FF77FF]%s[white]  %s LP\n", pos.Pool, pos.LPTokens))
+				continue
+			}
+			totalNetWorth = totalNetWorth.Add(value)
+			if stablecoins[strings.ToLower(pos.Coin)] && stablecoins[strings.ToLower(pos.PairCoin)] {
+				liquidNetWorth = liquidNetWorth.Add(value)
+			} else {
+				nonLiquidNetWorth = nonLiquidNetWorth.Add(value)
+			}
+			content.WriteString(fmt.Sprintf("[#FF77FF]%s[white]  %s LP [#AAAAAA](%s)[white]\n",
+				pos.Pool, pos.LPTokens, util.FormatUSDValue(value)))
 		}
 	}
 
 	// Add net worth breakdown at the bottom
-	if totalNetWorth > 0 {
+	if totalNetWorth.IsPositive() {
 		content.WriteString("\n")
 		content.WriteString(fmt.Sprintf("[::b][#FF6600]Non-Stables: %s[white]\n", util.FormatUSDValue(nonLiquidNetWorth)))
 		content.WriteString(fmt.Sprintf("[::b][#00FF00]Stables: %s[white]\n", util.FormatUSDValue(liquidNetWorth)))
@@ -1566,10 +2016,10 @@ func createWalletListView(wallets []*model.Wallet, categories []*model.Category)
 		}
 		content.WriteString(fmt.Sprintf(" [#888888](%s)[white]\n", wallet.Address))
 
-		// Get coins from balances
-		coinMap := make(map[string]float64)
+		// Get coins from balances, staked amounts included
+		coinMap := make(map[string]decimal.Decimal)
 		for _, balance := range wallet.Balances {
-			coinMap[balance.Coin] = balance.Amount
+			coinMap[balance.Coin] = coinMap[balance.Coin].Add(balance.Amount)
 		}
 
 		// Sort coins
@@ -1582,20 +2032,20 @@ func createWalletListView(wallets []*model.Wallet, categories []*model.Category)
 		// Add balances for each coin (skip zero balances)
 		for _, coin := range coins {
 			balance := coinMap[coin]
-			if balance == 0 {
+			if balance.IsZero() {
 				continue
 			}
 			// Add USD value if available
 			if err == nil {
 				if price, exists := prices[strings.ToLower(coin)]; exists {
-					usdValue := balance * price
-					content.WriteString(fmt.Sprintf("  %s: [#00FF00]%.2f[white] [#AAAAAA](%s)[white]\n",
-						coin, balance, util.FormatUSDValue(usdValue)))
+					usdValue := util.USDValue(balance, price)
+					content.WriteString(fmt.Sprintf("  %s: [#00FF00]%s[white] [#AAAAAA](%s)[white]\n",
+						coin, balance.StringFixed(2), util.FormatUSDValue(usdValue)))
 				} else {
-					content.WriteString(fmt.Sprintf("  %s: [#00FF00]%.2f[white]\n", coin, balance))
+					content.WriteString(fmt.Sprintf("  %s: [#00FF00]%s[white]\n", coin, balance.StringFixed(2)))
 				}
 			} else {
-				content.WriteString(fmt.Sprintf("  %s: [#00FF00]%.2f[white]\n", coin, balance))
+				content.WriteString(fmt.Sprintf("  %s: [#00FF00]%s[white]\n", coin, balance.StringFixed(2)))
 			}
 		}
 		content.WriteString("\n")
@@ -1605,8 +2055,75 @@ func createWalletListView(wallets []*model.Wallet, categories []*model.Category)
 	return view
 }
 
+// createSchedulesView lists the next occurrence of each schedule, overdue
+// ones first
+func createSchedulesView(schedules []*model.Schedule) *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+
+	view.SetBorder(true).SetTitle(" Scheduled ")
+
+	type item struct {
+		sch     *model.Schedule
+		date    time.Time
+		overdue int
+	}
+	var items []item
+	for _, sch := range schedules {
+		due, next, err := storage.ScheduleDue(sch, time.Now(), overdueLimit)
+		switch {
+		case err != nil:
+			continue
+		case len(due) > 0:
+			items = append(items, item{sch, due[0], len(due)})
+		case !next.IsZero():
+			items = append(items, item{sch, next, 0})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if (items[i].overdue > 0) != (items[j].overdue > 0) {
+			return items[i].overdue > 0
+		}
+		return items[i].date.Before(items[j].date)
+	})
+
+	var content strings.Builder
+	overdue := false
+	for _, it := range items {
+		if it.overdue > 0 {
+			overdue = true
+			content.WriteString(fmt.Sprintf("[#FF5555]⚠ %s %s[white]  %s  [#FF5555]%s overdue[white]\n",
+				it.date.Local().Format("Jan 02"), it.sch.Name, scheduleAmount(it.sch.Tx), formatOverdue(it.overdue)))
+			continue
+		}
+		content.WriteString(fmt.Sprintf("[#666666]%s[white] [#00FFFF]⟳[white] %s  %s\n",
+			it.date.Local().Format("Jan 02"), it.sch.Name, scheduleAmount(it.sch.Tx)))
+	}
+	if len(items) == 0 {
+		content.WriteString("[#AAAAAA]All schedules have ended[white]\n")
+	}
+	if overdue {
+		content.WriteString("\n[#666666]:run to record overdue transactions[white]\n")
+	}
+
+	view.SetText(content.String())
+	return view
+}
+
+// scheduleAmount describes what a scheduled transaction moves, briefly
+func scheduleAmount(tx *model.Tx) string {
+	switch tx.Type {
+	case model.TxTypeSwap:
+		return fmt.Sprintf("%s %s → %s", tx.SellAmount.StringFixed(2), tx.SellCoin, tx.BuyCoin)
+	case model.TxTypeMulti:
+		return fmt.Sprintf("%d legs", len(tx.Legs))
+	}
+	return fmt.Sprintf("%s %s", tx.Amount.StringFixed(2), tx.Coin)
+}
+
 // createCategoryBalanceView creates a view showing balances by category
-func createCategoryBalanceView(wallets []*model.Wallet, categories []*model.Category) *tview.TextView {
+func createCategoryBalanceView(wallets []*model.Wallet, categories []*model.Category, positions []*model.Position) *tview.TextView {
 	view := tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true)
@@ -1626,7 +2143,7 @@ func createCategoryBalanceView(wallets []*model.Wallet, categories []*model.Cate
 	}
 
 	// Calculate balance by category
-	balanceByCategory := make(map[string]map[string]float64)
+	balanceByCategory := make(map[string]map[string]decimal.Decimal)
 	for _, wallet := range wallets {
 		category := wallet.Category
 		if category == "" {
@@ -1634,11 +2151,34 @@ func createCategoryBalanceView(wallets []*model.Wallet, categories []*model.Cate
 		}
 
 		if _, ok := balanceByCategory[category]; !ok {
-			balanceByCategory[category] = make(map[string]float64)
+			balanceByCategory[category] = make(map[string]decimal.Decimal)
 		}
 
 		for _, balance := range wallet.Balances {
-			balanceByCategory[category][balance.Coin] += balance.Amount
+			balanceByCategory[category][balance.Coin] = balanceByCategory[category][balance.Coin].Add(balance.Amount)
+		}
+	}
+
+	// Value LP positions by the category of the wallet holding them
+	lpByCategory := make(map[string]decimal.Decimal)
+	if len(positions) > 0 {
+		walletCategory := make(map[string]string, len(wallets))
+		var lpCoins []string
+		for _, wallet := range wallets {
+			walletCategory[wallet.Name] = wallet.Category
+		}
+		for _, pos := range positions {
+			lpCoins = append(lpCoins, pos.Coin, pos.PairCoin)
+		}
+		prices, _ := util.GetCoinPrices(lpCoins)
+		for _, pos := range positions {
+			category := walletCategory[pos.Wallet]
+			if category == "" {
+				category = "Uncategorized"
+			}
+			if value, priced := storage.PositionValue(pos, prices); priced {
+				lpByCategory[category] = lpByCategory[category].Add(value)
+			}
 		}
 	}
 
@@ -1653,7 +2193,8 @@ func createCategoryBalanceView(wallets []*model.Wallet, categories []*model.Cate
 	var content strings.Builder
 	for _, catName := range categoryNames {
 		// Skip if no balances
-		if len(balanceByCategory[catName]) == 0 {
+		lpValue, hasLP := lpByCategory[catName]
+		if len(balanceByCategory[catName]) == 0 && !hasLP {
 			continue
 		}
 
@@ -1676,10 +2217,13 @@ func createCategoryBalanceView(wallets []*model.Wallet, categories []*model.Cate
 		// Add balances for each coin in this category (skip zero balances)
 		for _, coin := range coins {
 			balance := balanceByCategory[catName][coin]
-			if balance == 0 {
+			if balance.IsZero() {
 				continue
 			}
-			content.WriteString(fmt.Sprintf("  %s: [#00FF00]%.2f[white]\n", coin, balance))
+			content.WriteString(fmt.Sprintf("  %s: [#00FF00]%s[white]\n", coin, balance.StringFixed(2)))
+		}
+		if hasLP {
+			content.WriteString(fmt.Sprintf("  [#FF77FF]LP[white]: [#00FF00]%s[white]\n", util.FormatUSDValue(lpValue)))
 		}
 		content.WriteString("\n")
 	}
@@ -1710,7 +2254,7 @@ func createCategoryChartView(wallets []*model.Wallet, categories []*model.Catego
 	categoryColors["Uncategorized"] = "#FFFFFF"
 
 	// Calculate balances by category and coin
-	balanceByCategoryAndCoin := make(map[string]map[string]float64)
+	balanceByCategoryAndCoin := make(map[string]map[string]decimal.Decimal)
 	allCoins := make(map[string]bool)
 
 	for _, wallet := range wallets {
@@ -1720,11 +2264,11 @@ func createCategoryChartView(wallets []*model.Wallet, categories []*model.Catego
 		}
 
 		if _, ok := balanceByCategoryAndCoin[category]; !ok {
-			balanceByCategoryAndCoin[category] = make(map[string]float64)
+			balanceByCategoryAndCoin[category] = make(map[string]decimal.Decimal)
 		}
 
 		for _, balance := range wallet.Balances {
-			balanceByCategoryAndCoin[category][balance.Coin] += balance.Amount
+			balanceByCategoryAndCoin[category][balance.Coin] = balanceByCategoryAndCoin[category][balance.Coin].Add(balance.Amount)
 			allCoins[balance.Coin] = true
 		}
 	}
@@ -1748,14 +2292,14 @@ func createCategoryChartView(wallets []*model.Wallet, categories []*model.Catego
 
 	for _, coin := range coinsList {
 		// Calculate total balance for this coin
-		totalCoinBalance := 0.0
+		totalCoinBalance := decimal.Zero
 		for _, balances := range balanceByCategoryAndCoin {
 			if amount, ok := balances[coin]; ok {
-				totalCoinBalance += amount
+				totalCoinBalance = totalCoinBalance.Add(amount)
 			}
 		}
 
-		if totalCoinBalance == 0 {
+		if totalCoinBalance.IsZero() {
 			continue
 		}
 
@@ -1765,22 +2309,22 @@ func createCategoryChartView(wallets []*model.Wallet, categories []*model.Catego
 		// Sort categories by balance for this coin (descending)
 		type categoryStat struct {
 			name    string
-			balance float64
+			balance decimal.Decimal
 		}
 
 		stats := make([]categoryStat, 0)
 		for cat, balances := range balanceByCategoryAndCoin {
-			if amount, ok := balances[coin]; ok && amount > 0 {
+			if amount, ok := balances[coin]; ok && amount.IsPositive() {
 				stats = append(stats, categoryStat{cat, amount})
 			}
 		}
 
 		sort.Slice(stats, func(i, j int) bool {
-			return stats[i].balance > stats[j].balance
+			return stats[i].balance.GreaterThan(stats[j].balance)
 		})
 
 		// Find the maximum balance for scaling
-		maxBalance := 0.0
+		maxBalance := decimal.Zero
 		if len(stats) > 0 {
 			maxBalance = stats[0].balance
 		}
@@ -1794,24 +2338,24 @@ func createCategoryChartView(wallets []*model.Wallet, categories []*model.Catego
 			}
 
 			// Calculate bar length
-			barLength := int((stat.balance / maxBalance) * float64(maxBarLength))
+			barLength := int(stat.balance.Div(maxBalance).InexactFloat64() * float64(maxBarLength))
 			if barLength < 1 {
 				barLength = 1
 			}
 
 			// Calculate percentage
-			percentage := (stat.balance / totalCoinBalance) * 100
+			percentage := stat.balance.Div(totalCoinBalance).InexactFloat64() * 100
 
 			// Create the bar
 			bar := strings.Repeat("█", barLength)
 
 			// Add category name, bar, balance, and percentage
-			content.WriteString(fmt.Sprintf(" [%s]■[white] [::b]%s[:-] [%s]%s[white] [#00FF00]%.2f[white] ([#FFFF00]%.1f%%[white])\n",
+			content.WriteString(fmt.Sprintf(" [%s]■[white] [::b]%s[:-] [%s]%s[white] [#00FF00]%s[white] ([#FFFF00]%.1f%%[white])\n",
 				catColor,
 				stat.name,
 				catColor,
 				bar,
-				stat.balance,
+				stat.balance.StringFixed(2),
 				percentage,
 			))
 		}
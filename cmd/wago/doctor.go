@@ -0,0 +1,70 @@
+package wago
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+var doctorFix bool
+
+func init() {
+	// Doctor command
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the ledger for problems",
+		Long: `Check the ledger for transactions referencing missing wallets, balances that
+disagree with the transactions, wallets in deleted categories, duplicate
+addresses, coins spelled with different case, negative balances and zero prices.
+With --fix, everything that can be repaired automatically is repaired in a
+single change that 'wago undo' reverts.`,
+		Args: cobra.NoArgs,
+		Run:  runDoctor,
+	}
+
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair the problems that can be fixed automatically")
+
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	issues, err := s.Doctor(doctorFix)
+	if err != nil {
+		er(fmt.Sprintf("Failed to check ledger: %v", err))
+		return
+	}
+	if len(issues) == 0 {
+		fmt.Println(color.GreenString("No problems found"))
+		return
+	}
+
+	fixable := 0
+	for _, issue := range issues {
+		mark := color.YellowString("!")
+		if issue.Fixable {
+			fixable++
+			if doctorFix {
+				mark = color.GreenString("✓")
+			}
+		}
+		fmt.Printf("  %s %s %s\n", mark, color.New(color.FgHiBlack).Sprintf("[%s]", issue.Kind), issue.Message)
+	}
+
+	switch {
+	case doctorFix:
+		fmt.Printf("%d problem(s) found, %d fixed\n", len(issues), fixable)
+	case fixable > 0:
+		fmt.Printf("%d problem(s) found, %d can be fixed with --fix\n", len(issues), fixable)
+	default:
+		fmt.Printf("%d problem(s) found\n", len(issues))
+	}
+}
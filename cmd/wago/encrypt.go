@@ -0,0 +1,114 @@
+package wago
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+	"golang.org/x/term"
+)
+
+func init() {
+	// Encrypt command
+	encryptCmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the data file with a passphrase",
+		Long: `Encrypt the data file at rest with AES-GCM, using a key derived from a passphrase.
+The passphrase is read from WAGO_PASSPHRASE, the --keyfile/WAGO_KEYFILE file, or a prompt.`,
+		Args: cobra.NoArgs,
+		Run:  encryptLedger,
+	}
+
+	// Decrypt command
+	decryptCmd := &cobra.Command{
+		Use:   "decrypt",
+		Short: "Store the data file as plain JSON again",
+		Long:  `Decrypt the data file and store it as plain JSON.`,
+		Args:  cobra.NoArgs,
+		Run:   decryptLedger,
+	}
+
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+}
+
+func encryptLedger(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	if s.IsEncrypted() {
+		er("Data file is already encrypted")
+		return
+	}
+
+	passphrase, err := storage.ConfiguredPassphrase()
+	if err != nil {
+		er(err)
+		return
+	}
+	if passphrase == "" {
+		passphrase, err = promptPassphrase("New passphrase: ")
+		if err != nil {
+			er(err)
+			return
+		}
+		confirm, err := promptPassphrase("Repeat passphrase: ")
+		if err != nil {
+			er(err)
+			return
+		}
+		if passphrase != confirm {
+			er("Passphrases do not match")
+			return
+		}
+	}
+
+	if err := s.Encrypt(passphrase); err != nil {
+		er(fmt.Sprintf("Failed to encrypt data file: %v", err))
+		return
+	}
+
+	fmt.Println("Data file encrypted. Keep the passphrase safe: it cannot be recovered.")
+}
+
+func decryptLedger(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	if !s.IsEncrypted() {
+		er("Data file is not encrypted")
+		return
+	}
+
+	if err := s.Decrypt(); err != nil {
+		er(fmt.Sprintf("Failed to decrypt data file: %v", err))
+		return
+	}
+
+	fmt.Println("Data file decrypted")
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it
+func promptPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for a passphrase: set WAGO_PASSPHRASE or WAGO_KEYFILE")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
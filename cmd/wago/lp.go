@@ -0,0 +1,105 @@
+package wago
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/model"
+	"github.com/vasylcode/wago/internal/storage"
+	"github.com/vasylcode/wago/internal/util"
+)
+
+func init() {
+	// LP command
+	lpCmd := &cobra.Command{
+		Use:   "lp [wallet]",
+		Short: "List liquidity pool positions",
+		Long: `List the open liquidity pool positions of all wallets, or of one wallet, with
+their LP tokens, the underlying assets still attributed to them, their value at
+the current prices and their cost basis. Positions are opened with
+'wago tx add --type lp-add' and reduced or closed with --type lp-remove.`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  listPositions,
+	}
+
+	rootCmd.AddCommand(lpCmd)
+}
+
+func listPositions(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	wallet := ""
+	if len(args) > 0 {
+		wallet = args[0]
+		if _, err := s.GetWallet(wallet); err != nil {
+			er(fmt.Sprintf("Failed to get wallet: %v", err))
+			return
+		}
+	}
+
+	positions := s.ListPositions(wallet)
+	if len(positions) == 0 {
+		fmt.Println("No liquidity positions found")
+		return
+	}
+
+	titleColor := color.New(color.Bold, color.Underline)
+	titleColor.Println("Liquidity Positions:")
+
+	prices := s.GetPrices()
+	totalValue, totalCost := decimal.Zero, decimal.Zero
+	current := ""
+	for _, pos := range positions {
+		if pos.Wallet != current {
+			color.New(color.Bold).Println(pos.Wallet)
+			current = pos.Wallet
+		}
+		printPosition(pos, prices, "  ")
+		value, _ := storage.PositionValue(pos, prices)
+		totalValue = totalValue.Add(value)
+		totalCost = totalCost.Add(pos.CostBasis)
+	}
+
+	if len(positions) >= 2 {
+		fmt.Printf("Total: %s (cost %s, %s)\n", util.FormatUSDValue(totalValue), util.FormatUSDValue(totalCost), formatPnL(totalValue.Sub(totalCost)))
+	}
+}
+
+// printPosition prints one liquidity position with its value and cost basis
+func printPosition(pos *model.Position, prices map[string]float64, indent string) {
+	bold := color.New(color.Bold)
+	gray := color.New(color.FgHiBlack)
+
+	valueStr := gray.Sprint("no price")
+	value, priced := storage.PositionValue(pos, prices)
+	if priced {
+		valueStr = util.FormatUSDValue(value)
+		if !pos.CostBasis.IsZero() {
+			valueStr += " " + formatPnL(value.Sub(pos.CostBasis))
+		}
+	}
+
+	fmt.Printf("%s%s %s LP  %s %s + %s %s  %s %s\n",
+		indent,
+		color.New(color.FgHiMagenta, color.Bold).Sprint(pos.Pool),
+		pos.LPTokens.String(),
+		pos.Amount.StringFixed(2), bold.Sprint(pos.Coin),
+		pos.PairAmount.StringFixed(2), bold.Sprint(pos.PairCoin),
+		valueStr,
+		gray.Sprintf("(cost %s)", util.FormatUSDValue(pos.CostBasis)))
+}
+
+// formatPnL formats a USD gain green and a loss red
+func formatPnL(pnl decimal.Decimal) string {
+	if pnl.IsNegative() {
+		return color.RedString("-%s", util.FormatUSDValue(pnl.Neg()))
+	}
+	return color.GreenString("+%s", util.FormatUSDValue(pnl))
+}
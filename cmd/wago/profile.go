@@ -0,0 +1,41 @@
+package wago
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+func init() {
+	// Profile command
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "List ledger profiles",
+		Long: `List the available ledger profiles. Each profile keeps its own data file;
+select one with --profile NAME or the WAGO_PROFILE environment variable.`,
+		Run: listProfiles,
+	}
+
+	// Add profile command to root command
+	rootCmd.AddCommand(profileCmd)
+}
+
+func listProfiles(cmd *cobra.Command, args []string) {
+	profiles, err := storage.ListProfiles()
+	if err != nil {
+		er(fmt.Sprintf("Failed to list profiles: %v", err))
+		return
+	}
+
+	current := storage.CurrentProfile()
+	fmt.Println(color.New(color.Bold).Sprint("Profiles:"))
+	for _, name := range profiles {
+		if name == current {
+			fmt.Printf("  %s %s\n", color.New(color.FgGreen).Sprint("▶"), color.New(color.Bold).Sprint(name))
+		} else {
+			fmt.Printf("    %s\n", name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package wago
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+var rebuildDryRun bool
+
+func init() {
+	// Rebuild command
+	rebuildCmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Recompute wallet balances from transactions",
+		Long: `Recompute every wallet balance by replaying all transactions in date order,
+and report where the stored balances differed.`,
+		Args: cobra.NoArgs,
+		Run:  rebuildBalances,
+	}
+
+	rebuildCmd.Flags().BoolVar(&rebuildDryRun, "dry-run", false, "Only report differences, don't update balances")
+
+	rootCmd.AddCommand(rebuildCmd)
+}
+
+func rebuildBalances(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	changes, err := s.Rebuild(rebuildDryRun)
+	if err != nil {
+		er(fmt.Sprintf("Failed to rebuild balances: %v", err))
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Println("All balances match the transaction history")
+		return
+	}
+
+	fmt.Println(color.New(color.Bold).Sprint("Balance differences:"))
+	for _, change := range changes {
+		fmt.Printf("  %s %s: %s → %s\n",
+			change.Wallet,
+			color.New(color.Bold).Sprint(change.Coin),
+			change.Old,
+			change.New)
+	}
+
+	if rebuildDryRun {
+		fmt.Printf("%d balance(s) would change; run without --dry-run to fix\n", len(changes))
+		return
+	}
+	fmt.Printf("%d balance(s) updated\n", len(changes))
+}
@@ -5,9 +5,16 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
 	"github.com/vasylcode/wago/internal/version"
 )
 
+var (
+	dataDir string
+	profile string
+	keyFile string
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "wago",
 	Short: "Wago - A simple JSON-based wallet tracker",
@@ -35,10 +42,18 @@ func Execute() error {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.Version = version.Version
+
+	rootCmd.PersistentFlags().StringVar(&dataDir, "data-dir", "", "Data directory (default $WAGO_HOME or ~/.wago)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Named profile to use (default $WAGO_PROFILE or the main ledger)")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "keyfile", "", "File containing the passphrase of an encrypted data file")
+
+	storage.PassphrasePrompt = promptPassphrase
 }
 
 func initConfig() {
-	// Initialize configuration if needed
+	if err := storage.Configure(storage.Options{DataDir: dataDir, Profile: profile, KeyFile: keyFile}); err != nil {
+		er(err)
+	}
 }
 
 func er(msg interface{}) {
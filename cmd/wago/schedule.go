@@ -0,0 +1,242 @@
+package wago
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/model"
+	"github.com/vasylcode/wago/internal/storage"
+	"github.com/vasylcode/wago/internal/util"
+)
+
+var (
+	scheduleEvery  string
+	scheduleUntil  string
+	scheduleDryRun bool
+)
+
+// overdueLimit caps how many missed occurrences are counted for display
+const overdueLimit = 100
+
+func init() {
+	// Schedule command
+	scheduleCmd := &cobra.Command{
+		Use:     "schedule",
+		Aliases: []string{"sched"},
+		Short:   "Manage recurring transactions",
+		Long: `Add, delete, run, and list recurring transactions such as DCA swaps,
+salaries and subscriptions. Listing shows the next occurrence of each
+schedule and how many are overdue.`,
+		Run: listSchedules,
+	}
+
+	// Add subcommand
+	addScheduleCmd := &cobra.Command{
+		Use:   "add [name]",
+		Short: "Add a recurring transaction",
+		Long: `Add a schedule recording a transaction, given with the flags of 'wago tx add',
+on every occurrence of --every: an interval like 1d, 2w, 1m or 1y (or daily,
+weekly, monthly, yearly), or a cron expression like "0 9 * * 1" for Mondays
+at 9:00. --date is the first occurrence and intervals count from it; monthly
+occurrences keep its day, or the last day of shorter months. --until ends the
+schedule. Nothing is recorded until 'wago schedule run'.`,
+		Args: cobra.ExactArgs(1),
+		Run:  addSchedule,
+	}
+
+	// Delete subcommand
+	delScheduleCmd := &cobra.Command{
+		Use:   "del [name]",
+		Short: "Delete a schedule",
+		Long:  `Delete a schedule. Transactions it already recorded are kept.`,
+		Args:  cobra.ExactArgs(1),
+		Run:   deleteSchedule,
+	}
+
+	// Run subcommand
+	runScheduleCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Record due scheduled transactions",
+		Long: `Record every occurrence of every schedule that is due by now as a
+transaction, in one change. Running again records nothing new, and
+occurrences already recorded, e.g. on a synced copy of the ledger, are
+skipped.`,
+		Args: cobra.NoArgs,
+		Run:  runSchedules,
+	}
+
+	// Add flags to add command
+	addTxFlags(addScheduleCmd)
+	addScheduleCmd.Flags().StringVarP(&scheduleEvery, "every", "e", "", "Recurrence: 1d, 2w, 1m, 1y, daily, weekly, monthly, yearly or a cron expression")
+	addScheduleCmd.Flags().StringVar(&scheduleUntil, "until", "", "Last date of the schedule (default never ends)")
+	addScheduleCmd.MarkFlagRequired("every")
+
+	// Add flags to run command
+	runScheduleCmd.Flags().BoolVar(&scheduleDryRun, "dry-run", false, "List the due occurrences without recording them")
+
+	// Add subcommands to schedule command
+	scheduleCmd.AddCommand(addScheduleCmd)
+	scheduleCmd.AddCommand(delScheduleCmd)
+	scheduleCmd.AddCommand(runScheduleCmd)
+
+	// Add schedule command to root command
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+func addSchedule(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	tx, err := buildTransaction(s)
+	if err != nil {
+		er(err.Error())
+		return
+	}
+
+	sch := &model.Schedule{Name: args[0], Rule: scheduleEvery, Tx: tx}
+	if scheduleUntil != "" {
+		until, err := util.ParseDate(scheduleUntil)
+		if err != nil {
+			er(err.Error())
+			return
+		}
+		sch.End = &until
+	}
+
+	if err := s.AddSchedule(sch); err != nil {
+		er(fmt.Sprintf("Failed to add schedule: %v", err))
+		return
+	}
+
+	fmt.Printf("Schedule '%s' added successfully\n", sch.Name)
+}
+
+func deleteSchedule(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	if err := s.DeleteSchedule(args[0]); err != nil {
+		er(fmt.Sprintf("Failed to delete schedule: %v", err))
+		return
+	}
+
+	fmt.Printf("Schedule '%s' deleted successfully\n", args[0])
+}
+
+func runSchedules(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	if scheduleDryRun {
+		count := 0
+		for _, sch := range sortedSchedules(s) {
+			due, _, err := storage.ScheduleDue(sch, time.Now(), overdueLimit)
+			if err != nil {
+				er(fmt.Sprintf("Failed to check schedule '%s': %v", sch.Name, err))
+				return
+			}
+			for _, date := range due {
+				fmt.Printf("  %s: %s\n", color.New(color.Bold).Sprint(sch.Name), txSummary(scheduledTx(sch, date)))
+			}
+			count += len(due)
+		}
+		fmt.Printf("%d occurrence(s) due\n", count)
+		return
+	}
+
+	recorded, err := s.RunSchedules(time.Now())
+	if err != nil {
+		er(fmt.Sprintf("Failed to run schedules: %v", err))
+		return
+	}
+	for _, tx := range recorded {
+		fmt.Printf("  %s\n", txSummary(tx))
+	}
+	fmt.Printf("Recorded %d scheduled transaction(s)\n", len(recorded))
+}
+
+func listSchedules(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	schedules := sortedSchedules(s)
+	if len(schedules) == 0 {
+		fmt.Println("No schedules found")
+		return
+	}
+
+	titleColor := color.New(color.Bold, color.Underline)
+	titleColor.Println("Schedules:")
+
+	gray := color.New(color.FgHiBlack)
+	for _, sch := range schedules {
+		due, next, err := storage.ScheduleDue(sch, time.Now(), overdueLimit)
+		if err != nil {
+			er(fmt.Sprintf("Failed to check schedule '%s': %v", sch.Name, err))
+			return
+		}
+
+		status := ""
+		switch {
+		case len(due) > 0:
+			next = due[0]
+			status = color.New(color.FgRed, color.Bold).Sprintf("  %s overdue", formatOverdue(len(due)))
+		case next.IsZero():
+			status = gray.Sprint("  ended")
+			next = sch.Tx.Date
+			if sch.Last != nil {
+				next = *sch.Last
+			}
+		}
+
+		fmt.Printf("  %s %s  %s%s\n",
+			color.New(color.Bold).Sprint(sch.Name),
+			gray.Sprintf("(%s)", sch.Rule),
+			txSummary(scheduledTx(sch, next)),
+			status)
+	}
+}
+
+// sortedSchedules returns the schedules sorted by name
+func sortedSchedules(s storage.Store) []*model.Schedule {
+	schedules := s.ListSchedules()
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules
+}
+
+// scheduledTx returns the template of sch dated date, for display
+func scheduledTx(sch *model.Schedule, date time.Time) *model.Tx {
+	tx := *sch.Tx
+	tx.Date = date
+	return &tx
+}
+
+// formatOverdue formats a count of overdue occurrences, which stops at
+// overdueLimit
+func formatOverdue(count int) string {
+	if count >= overdueLimit {
+		return fmt.Sprintf("%d+", overdueLimit)
+	}
+	return fmt.Sprintf("%d", count)
+}
@@ -0,0 +1,108 @@
+package wago
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+	"golang.org/x/term"
+)
+
+var (
+	syncOurs   bool
+	syncTheirs bool
+)
+
+func init() {
+	// Sync command
+	syncCmd := &cobra.Command{
+		Use:   "sync [path]",
+		Short: "Merge the ledger with a shared copy",
+		Long: `Three-way merge the ledger with another wago.json, e.g. one in a shared
+folder or a git repository, using the state of the last sync with that file as
+the common ancestor. Changes made on only one side are taken as they are;
+wallets, categories and contacts merge by name, transactions by ID and prices
+by the most recently set. Records changed differently on both sides are
+conflicts: you are asked which side to keep, or --ours/--theirs decides all of
+them. Balances are replayed from the merged transactions, the result is
+written to both files, and 'wago undo' reverts it locally.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runSync,
+	}
+
+	syncCmd.Flags().BoolVar(&syncOurs, "ours", false, "Keep this ledger's side of every conflict")
+	syncCmd.Flags().BoolVar(&syncTheirs, "theirs", false, "Keep the other ledger's side of every conflict")
+	syncCmd.MarkFlagsMutuallyExclusive("ours", "theirs")
+
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	resolve := promptConflict
+	if syncOurs || syncTheirs {
+		resolve = func(conflict storage.SyncConflict) (storage.SyncChoice, error) {
+			if syncTheirs {
+				return storage.SyncTheirs, nil
+			}
+			return storage.SyncOurs, nil
+		}
+	}
+
+	result, err := s.Sync(args[0], resolve)
+	if err != nil {
+		er(fmt.Sprintf("Failed to sync: %v", err))
+		return
+	}
+
+	fmt.Printf("Synced: %d record(s) pulled, %d pushed, %d conflict(s)\n", result.Pulled, result.Pushed, result.Conflicts)
+	for _, change := range result.Balances {
+		fmt.Printf("  %s %s: %s → %s\n",
+			change.Wallet,
+			color.New(color.Bold).Sprint(change.Coin),
+			change.Old,
+			change.New)
+	}
+}
+
+// promptConflict asks which side of a sync conflict to keep
+func promptConflict(conflict storage.SyncConflict) (storage.SyncChoice, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return storage.SyncOurs, fmt.Errorf("%s '%s' changed on both sides: rerun with --ours or --theirs", conflict.Kind, conflict.Key)
+	}
+
+	side := func(value string) string {
+		if value == "" {
+			return color.RedString("(deleted)")
+		}
+		return value
+	}
+	fmt.Printf("%s %s '%s' changed on both sides\n", color.YellowString("Conflict:"), conflict.Kind, conflict.Key)
+	fmt.Printf("  ours:   %s\n", side(conflict.Ours))
+	fmt.Printf("  theirs: %s\n", side(conflict.Theirs))
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("Keep [o]urs or [t]heirs? ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return storage.SyncOurs, fmt.Errorf("failed to read answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "o", "ours":
+			return storage.SyncOurs, nil
+		case "t", "theirs":
+			return storage.SyncTheirs, nil
+		}
+	}
+}
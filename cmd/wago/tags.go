@@ -0,0 +1,152 @@
+package wago
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/model"
+	"github.com/vasylcode/wago/internal/storage"
+	"github.com/vasylcode/wago/internal/util"
+)
+
+func init() {
+	// Tags command
+	tagsCmd := &cobra.Command{
+		Use:   "tags",
+		Short: "List transaction tags",
+		Long: `List the tags used on transactions, with how many transactions carry each
+and their total USD value at the current prices. A transaction is valued by
+what it moves: the amount sent or received, the sold side of a swap, both
+coins of a liquidity pool change and the outgoing legs of a multi-leg
+transaction. Fees are left out. Tag transactions with 'wago tx add --tag'
+and list them with 'wago tx --tag'.`,
+		Args: cobra.NoArgs,
+		Run:  listTags,
+	}
+
+	rootCmd.AddCommand(tagsCmd)
+}
+
+// tagTotal sums the transactions carrying one tag
+type tagTotal struct {
+	tag      string
+	count    int
+	value    decimal.Decimal
+	unpriced int // transactions moving a coin without a price
+}
+
+func listTags(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	prices := s.GetPrices()
+	totals := make(map[string]*tagTotal)
+	untagged := 0
+	for _, tx := range s.ListTransactions() {
+		if len(tx.Tags) == 0 {
+			untagged++
+			continue
+		}
+		value, priced := txValue(tx, prices)
+		for _, tag := range tx.Tags {
+			total := totals[tag]
+			if total == nil {
+				total = &tagTotal{tag: tag}
+				totals[tag] = total
+			}
+			total.count++
+			total.value = total.value.Add(value)
+			if !priced {
+				total.unpriced++
+			}
+		}
+	}
+
+	if len(totals) == 0 {
+		fmt.Println("No tags found")
+		return
+	}
+
+	sorted := make([]*tagTotal, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, total)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].tag < sorted[j].tag
+	})
+
+	titleColor := color.New(color.Bold, color.Underline)
+	titleColor.Println("Tags:")
+
+	width := 0
+	for _, total := range sorted {
+		if len(total.tag) > width {
+			width = len(total.tag)
+		}
+	}
+	gray := color.New(color.FgHiBlack)
+	for _, total := range sorted {
+		unpricedStr := ""
+		if total.unpriced > 0 {
+			unpricedStr = gray.Sprintf("  (%d without a price)", total.unpriced)
+		}
+		fmt.Printf("  %s%s %4d tx  %s%s\n",
+			color.New(color.FgCyan, color.Bold).Sprint("#"+total.tag),
+			strings.Repeat(" ", width-len(total.tag)),
+			total.count,
+			util.FormatUSDValue(total.value),
+			unpricedStr)
+	}
+	if untagged > 0 {
+		gray.Printf("\n%d untagged transaction(s)\n", untagged)
+	}
+}
+
+// txValue returns the USD value of what tx moves at the current prices, and
+// whether every coin it moves has a price
+func txValue(tx *model.Tx, prices map[string]float64) (decimal.Decimal, bool) {
+	value, priced := decimal.Zero, true
+	add := func(amount decimal.Decimal, coin string) {
+		price, ok := prices[strings.ToLower(coin)]
+		if !ok {
+			priced = false
+		}
+		value = value.Add(amount.Abs().Mul(decimal.NewFromFloat(price)))
+	}
+
+	switch tx.Type {
+	case model.TxTypeSwap:
+		add(tx.SellAmount, tx.SellCoin)
+	case model.TxTypeLPAdd, model.TxTypeLPRemove:
+		add(tx.Amount, tx.Coin)
+		add(tx.PairAmount, tx.PairCoin)
+	case model.TxTypeMulti:
+		// Count what leaves, or what arrives if nothing does
+		outgoing := false
+		for _, leg := range tx.Legs {
+			if leg.Amount.IsNegative() {
+				outgoing = true
+				break
+			}
+		}
+		for _, leg := range tx.Legs {
+			if leg.Amount.IsNegative() == outgoing {
+				add(leg.Amount, leg.Coin)
+			}
+		}
+	default:
+		add(tx.Amount, tx.Coin)
+	}
+	return value, priced
+}
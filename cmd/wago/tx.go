@@ -1,37 +1,102 @@
 package wago
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 	"github.com/vasylcode/wago/internal/model"
 	"github.com/vasylcode/wago/internal/storage"
+	"github.com/vasylcode/wago/internal/util"
 )
 
 var (
 	txFromWallet string
 	txToWallet   string
 	txCoin       string
-	txAmount     float64
+	txAmount     decimal.Decimal
 	txNote       string
-	txFee        float64
+	txFee        decimal.Decimal
+	txFeeCoin    string
+	txFeeWallet  string
 	txSwapWallet string
 	txSellCoin   string
-	txSellAmount float64
+	txSellAmount decimal.Decimal
 	txBuyCoin    string
-	txBuyAmount  float64
+	txBuyAmount  decimal.Decimal
+	txHash       string
+	txDate       string
+	txNewID      bool
+	txKind       string
+
+	txFromChain      string
+	txToChain        string
+	txProtocol       string
+	txReceivedCoin   string
+	txReceivedAmount decimal.Decimal
+	txLegs           []string
+
+	txPool       string
+	txPairCoin   string
+	txPairAmount decimal.Decimal
+	txLPTokens   decimal.Decimal
+	txCostBasis  decimal.Decimal
+
+	txTags       []string
+	txFilterTags []string
 )
 
+// explicitTxTypes can't be told apart by the wallet flags and need --type
+var explicitTxTypes = []model.TxType{
+	model.TxTypeStake,
+	model.TxTypeUnstake,
+	model.TxTypeReward,
+	model.TxTypeAirdrop,
+	model.TxTypeIncome,
+	model.TxTypeBridge,
+	model.TxTypeMulti,
+	model.TxTypeLPAdd,
+	model.TxTypeLPRemove,
+}
+
+// decimalFlag parses a flag straight into a decimal, so amounts never pass
+// through float64
+type decimalFlag struct {
+	value *decimal.Decimal
+}
+
+func (f decimalFlag) String() string {
+	if f.value == nil {
+		return "0"
+	}
+	return f.value.String()
+}
+
+func (f decimalFlag) Set(s string) error {
+	amount, err := util.ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*f.value = amount
+	return nil
+}
+
+func (f decimalFlag) Type() string { return "decimal" }
+
 func init() {
 	// Transaction command
 	txCmd := &cobra.Command{
 		Use:     "tx",
 		Short:   "Manage transactions",
-		Long:    `Add and delete transactions.`,
+		Long:    `Add, import, edit, and delete transactions. With --tag only the
+transactions carrying every given tag are listed.`,
 		Run:     listTransactions,
 	}
 
@@ -39,8 +104,52 @@ func init() {
 	addTxCmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add a new transaction",
-		Long:  `Add a new transaction with the specified properties.`,
-		Run:   addTransaction,
+		Long: `Add a new transaction with the specified properties. Its ID is derived
+from the type, wallets, coins, amounts, fee, date and --hash, so adding the
+same transaction again is a no-op; --new-id records it anyway under a fresh
+ID. Without --date the transaction is dated to the current second, so pass
+--date or --hash for a rerun later on to be recognised as the same one.
+
+The type follows from the wallets given: --to alone is a deposit, --from alone
+a withdrawal, both a transfer and --swap a swap. Use --type for the rest:
+stake and unstake move --amount of --coin between the liquid and staked
+balances of the --from wallet; reward, airdrop and income credit the --to
+wallet, optionally naming the payer with --from. bridge moves --amount of
+--coin from the --from wallet to the --to wallet on another chain, which
+receives --received-amount (default --amount) of --received-coin (default
+--coin); the chains default to those of the wallets. A multi-leg transaction
+moves several assets at once, one --leg WALLET:AMOUNT:COIN per movement with
+a negative amount for what leaves the wallet or contact, e.g.
+--leg main:-1000:USDC --leg alice:500:USDC --leg bob:500:USDC. lp-add moves
+--amount of --coin and --pair-amount of --pair-coin from the --from wallet
+into the --pool position for --lp-tokens; lp-remove burns --lp-tokens of it
+and returns the amounts given. See 'wago lp' for the positions.
+
+Tags group transactions by project, client or grant, e.g. --tag grant-x.
+They are stored lowercase without the leading #; see 'wago tags'.`,
+		Run: addTransaction,
+	}
+
+	// Import subcommand
+	importTxCmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import transactions from a JSON file",
+		Long: `Import a JSON array of transactions in the format of wago.json. Transactions
+without an ID get one derived from their content, and transactions that are
+already recorded are skipped, so the same file can be imported repeatedly.
+Everything is added in one change, or nothing if any transaction is invalid.`,
+		Args: cobra.ExactArgs(1),
+		Run:  importTransactions,
+	}
+
+	// Edit subcommand
+	editTxCmd := &cobra.Command{
+		Use:   "edit [txID]",
+		Short: "Edit a transaction",
+		Long: `Change fields of a transaction, keeping its ID. Balances are recomputed so
+the old effect is reversed and the new one applied in a single save.`,
+		Args: cobra.ExactArgs(1),
+		Run:  editTransaction,
 	}
 
 	// Delete subcommand
@@ -53,75 +162,247 @@ func init() {
 	}
 
 	// Add flags to add command
-	addTxCmd.Flags().StringVarP(&txFromWallet, "from", "f", "", "Source wallet name (for withdraw or transfer)")
-	addTxCmd.Flags().StringVarP(&txToWallet, "to", "t", "", "Destination wallet name (for deposit or transfer)")
-	addTxCmd.Flags().StringVarP(&txSwapWallet, "swap", "s", "", "Wallet name for swap transaction")
-	addTxCmd.Flags().StringVarP(&txCoin, "coin", "c", "", "Coin/token symbol")
-	addTxCmd.Flags().Float64VarP(&txAmount, "amount", "a", 0, "Transaction amount")
-	addTxCmd.Flags().StringVarP(&txNote, "note", "n", "", "Transaction note")
-	addTxCmd.Flags().Float64VarP(&txFee, "fee", "F", 0, "Transaction fee (deducted from received amount)")
-	addTxCmd.Flags().StringVarP(&txSellCoin, "sell-coin", "S", "", "Coin to sell (swap transactions)")
-	addTxCmd.Flags().Float64VarP(&txSellAmount, "sell-amount", "A", 0, "Amount to sell (swap transactions)")
-	addTxCmd.Flags().StringVarP(&txBuyCoin, "buy-coin", "B", "", "Coin to buy (swap transactions)")
-	addTxCmd.Flags().Float64VarP(&txBuyAmount, "buy-amount", "M", 0, "Amount to buy (swap transactions)")
+	addTxFlags(addTxCmd)
+	addTxCmd.Flags().BoolVar(&txNewID, "new-id", false, "Use a fresh ID even if the transaction is already recorded")
+
+	// Add flags to edit command, named like the fields they set
+	editTxCmd.Flags().StringVarP(&txFromWallet, "from", "f", "", "Source wallet or contact")
+	editTxCmd.Flags().StringVarP(&txToWallet, "to", "t", "", "Destination wallet or contact")
+	editTxCmd.Flags().StringVarP(&txSwapWallet, "swap", "s", "", "Wallet of a swap")
+	editTxCmd.Flags().StringVarP(&txCoin, "coin", "c", "", "Coin/token symbol")
+	editTxCmd.Flags().VarP(decimalFlag{&txAmount}, "amount", "a", "Transaction amount")
+	editTxCmd.Flags().VarP(decimalFlag{&txFee}, "fee", "F", "Transaction fee")
+	editTxCmd.Flags().StringVar(&txFeeCoin, "fee-coin", "", "Coin the fee is paid in")
+	editTxCmd.Flags().StringVar(&txFeeWallet, "fee-wallet", "", "Wallet that pays the fee")
+	editTxCmd.Flags().StringVarP(&txSellCoin, "sell-coin", "S", "", "Coin sold in a swap")
+	editTxCmd.Flags().VarP(decimalFlag{&txSellAmount}, "sell-amount", "A", "Amount sold in a swap")
+	editTxCmd.Flags().StringVarP(&txBuyCoin, "buy-coin", "B", "", "Coin bought in a swap")
+	editTxCmd.Flags().VarP(decimalFlag{&txBuyAmount}, "buy-amount", "M", "Amount bought in a swap")
+	editTxCmd.Flags().StringVar(&txDate, "date", "", "Transaction date, e.g. 2024-03-01, 2024-03-01T14:30, yesterday, -3d, with an optional [Zone]")
+	editTxCmd.Flags().StringVarP(&txNote, "note", "n", "", "Transaction note")
+	editTxCmd.Flags().StringVar(&txHash, "hash", "", "On-chain transaction hash")
+	editTxCmd.Flags().StringVar(&txFromChain, "from-chain", "", "Source chain of a bridge")
+	editTxCmd.Flags().StringVar(&txToChain, "to-chain", "", "Destination chain of a bridge")
+	editTxCmd.Flags().StringVar(&txProtocol, "protocol", "", "Bridge protocol")
+	editTxCmd.Flags().StringVar(&txReceivedCoin, "received-coin", "", "Token received by a bridge")
+	editTxCmd.Flags().Var(decimalFlag{&txReceivedAmount}, "received-amount", "Amount received by a bridge")
+	editTxCmd.Flags().String("legs", "", "Replace the legs of a multi-leg transaction: WALLET:AMOUNT:COIN,...")
+	editTxCmd.Flags().StringVar(&txPool, "pool", "", "Liquidity pool position")
+	editTxCmd.Flags().StringVar(&txPairCoin, "pair-coin", "", "Second coin of a liquidity pool")
+	editTxCmd.Flags().Var(decimalFlag{&txPairAmount}, "pair-amount", "Amount of the second coin of a liquidity pool")
+	editTxCmd.Flags().Var(decimalFlag{&txLPTokens}, "lp-tokens", "LP tokens minted or burnt")
+	editTxCmd.Flags().Var(decimalFlag{&txCostBasis}, "cost-basis", "USD value deposited by lp-add")
+	editTxCmd.Flags().String("tags", "", "Replace the tags: TAG,... (empty to clear)")
+
+	// Add flags to list command
+	txCmd.Flags().StringSliceVar(&txFilterTags, "tag", nil, "Only list transactions with this tag (repeatable)")
+
+	// Add flags to import command
+	importTxCmd.Flags().BoolVar(&txNewID, "new-id", false, "Use fresh IDs instead of content-derived ones")
 
 	// Add subcommands to tx command
 	txCmd.AddCommand(addTxCmd)
+	txCmd.AddCommand(importTxCmd)
+	txCmd.AddCommand(editTxCmd)
 	txCmd.AddCommand(delTxCmd)
 
 	// Add tx command to root command
 	rootCmd.AddCommand(txCmd)
 }
 
+// addTxFlags registers the flags buildTransaction reads on cmd
+func addTxFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&txFromWallet, "from", "f", "", "Source wallet name (for withdraw or transfer)")
+	cmd.Flags().StringVarP(&txToWallet, "to", "t", "", "Destination wallet name (for deposit or transfer)")
+	cmd.Flags().StringVarP(&txSwapWallet, "swap", "s", "", "Wallet name for swap transaction")
+	cmd.Flags().StringVarP(&txCoin, "coin", "c", "", "Coin/token symbol")
+	cmd.Flags().VarP(decimalFlag{&txAmount}, "amount", "a", "Transaction amount")
+	cmd.Flags().StringVarP(&txNote, "note", "n", "", "Transaction note")
+	cmd.Flags().VarP(decimalFlag{&txFee}, "fee", "F", "Transaction fee, paid by the sending wallet in the sent coin by default")
+	cmd.Flags().StringVar(&txFeeCoin, "fee-coin", "", "Coin the fee is paid in, e.g. ETH for gas")
+	cmd.Flags().StringVar(&txFeeWallet, "fee-wallet", "", "Wallet that pays the fee")
+	cmd.Flags().StringVarP(&txSellCoin, "sell-coin", "S", "", "Coin to sell (swap transactions)")
+	cmd.Flags().VarP(decimalFlag{&txSellAmount}, "sell-amount", "A", "Amount to sell (swap transactions)")
+	cmd.Flags().StringVarP(&txBuyCoin, "buy-coin", "B", "", "Coin to buy (swap transactions)")
+	cmd.Flags().VarP(decimalFlag{&txBuyAmount}, "buy-amount", "M", "Amount to buy (swap transactions)")
+	cmd.Flags().StringVar(&txHash, "hash", "", "On-chain transaction hash")
+	cmd.Flags().StringVar(&txDate, "date", "", "Transaction date, e.g. 2024-03-01, 2024-03-01T14:30, yesterday, -3d, with an optional [Zone] (default now)")
+	cmd.Flags().StringVar(&txKind, "type", "", "Transaction type: stake, unstake, reward, airdrop, income, bridge, multi, lp-add or lp-remove")
+	cmd.Flags().StringArrayVar(&txLegs, "leg", nil, "Leg of a multi-leg transaction as WALLET:AMOUNT:COIN (repeatable)")
+	cmd.Flags().StringVar(&txPool, "pool", "", "Liquidity pool position, e.g. uniswap-eth-usdc")
+	cmd.Flags().StringVar(&txPairCoin, "pair-coin", "", "Second coin of a liquidity pool")
+	cmd.Flags().Var(decimalFlag{&txPairAmount}, "pair-amount", "Amount of the second coin of a liquidity pool")
+	cmd.Flags().Var(decimalFlag{&txLPTokens}, "lp-tokens", "LP tokens minted by lp-add or burnt by lp-remove")
+	cmd.Flags().Var(decimalFlag{&txCostBasis}, "cost-basis", "USD value deposited by lp-add (default from the current prices)")
+	cmd.Flags().StringVar(&txFromChain, "from-chain", "", "Source chain of a bridge (default the source wallet's chain)")
+	cmd.Flags().StringVar(&txToChain, "to-chain", "", "Destination chain of a bridge (default the destination wallet's chain)")
+	cmd.Flags().StringVar(&txProtocol, "protocol", "", "Bridge protocol, e.g. wormhole")
+	cmd.Flags().StringVar(&txReceivedCoin, "received-coin", "", "Token received on the destination chain (default --coin)")
+	cmd.Flags().Var(decimalFlag{&txReceivedAmount}, "received-amount", "Amount received on the destination chain (default --amount)")
+	cmd.Flags().StringSliceVar(&txTags, "tag", nil, "Tag the transaction, e.g. grant-x (repeatable or comma-separated)")
+}
+
 func addTransaction(cmd *cobra.Command, args []string) {
 	s, err := storage.New()
 	if err != nil {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
-	// Validate transaction type based on provided flags
-	if txFromWallet == "" && txToWallet == "" && txSwapWallet == "" {
-		er("Either --from, --to, or --swap wallet must be specified")
+	tx, err := buildTransaction(s)
+	if err != nil {
+		er(err.Error())
 		return
 	}
+	tx.ID = storage.ContentTxID(tx)
+	if txNewID {
+		tx.ID = s.GenerateTxID()
+	}
+
+	if err := s.AddTransaction(tx); err != nil {
+		if errors.Is(err, storage.ErrTxExists) {
+			fmt.Printf("Transaction already recorded as %s\n", tx.ID)
+			return
+		}
+		er(fmt.Sprintf("Failed to add transaction: %v", err))
+		return
+	}
+
+	fmt.Printf("Transaction added successfully\n")
+}
+
+// buildTransaction builds a transaction from the tx add flags, inferring its
+// type from the wallets given unless --type is set
+func buildTransaction(s storage.Store) (*model.Tx, error) {
+	var err error
+
+	// Coin symbols are stored uppercase, like the command palette does
+	txCoin = strings.ToUpper(txCoin)
+	txSellCoin = strings.ToUpper(txSellCoin)
+	txBuyCoin = strings.ToUpper(txBuyCoin)
+	txFeeCoin = strings.ToUpper(txFeeCoin)
+	txReceivedCoin = strings.ToUpper(txReceivedCoin)
+	txPairCoin = strings.ToUpper(txPairCoin)
+
+	// Legs make a multi-leg transaction
+	if len(txLegs) > 0 && txKind == "" {
+		txKind = string(model.TxTypeMulti)
+	}
+	multi := strings.EqualFold(txKind, string(model.TxTypeMulti))
+
+	// Validate transaction type based on provided flags
+	if txFromWallet == "" && txToWallet == "" && txSwapWallet == "" && !multi {
+		return nil, errors.New("Either --from, --to, or --swap wallet must be specified")
+	}
 
 	// Check for swap transaction
 	if txSwapWallet != "" {
 		// Validate swap-specific fields
 		if txSellCoin == "" || txBuyCoin == "" {
-			er("For swap transactions, both --sell-coin and --buy-coin must be specified")
-			return
+			return nil, errors.New("For swap transactions, both --sell-coin and --buy-coin must be specified")
 		}
-		if txSellAmount <= 0 || txBuyAmount <= 0 {
-			er("For swap transactions, both --sell-amount and --buy-amount must be greater than zero")
-			return
+		if !txSellAmount.IsPositive() || !txBuyAmount.IsPositive() {
+			return nil, errors.New("For swap transactions, both --sell-amount and --buy-amount must be greater than zero")
 		}
-	} else {
+	} else if !multi {
 		// Validate non-swap transactions
 		if txCoin == "" {
-			er("Coin must be specified with --coin flag")
-			return
+			return nil, errors.New("Coin must be specified with --coin flag")
 		}
-		if txAmount <= 0 {
-			er("Amount must be greater than zero")
-			return
+		if !txAmount.IsPositive() {
+			return nil, errors.New("Amount must be greater than zero")
+		}
+	}
+
+	// Whole seconds, since the date takes part in the content-derived ID
+	date := time.Now().Truncate(time.Second)
+	if txDate != "" {
+		if date, err = util.ParseDate(txDate); err != nil {
+			return nil, err
 		}
 	}
 
 	// Determine transaction type
 	var txType model.TxType
 	var fromAddress, toAddress string
+	var legs []model.Leg
 
-	if txSwapWallet != "" {
+	if txKind != "" {
+		txType = model.TxType(strings.ToLower(txKind))
+		switch txType {
+		case model.TxTypeStake, model.TxTypeUnstake:
+			// Staking moves funds within one wallet
+			wallet, err := s.GetWallet(txFromWallet)
+			if err != nil {
+				return nil, fmt.Errorf("Wallet '%s' not found: specify it with --from", txFromWallet)
+			}
+			fromAddress = wallet.Address
+
+		case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+			toWallet, err := s.GetWallet(txToWallet)
+			if err != nil {
+				return nil, fmt.Errorf("Destination wallet '%s' not found: specify it with --to", txToWallet)
+			}
+			toAddress = toWallet.Address
+			if txFromWallet != "" {
+				fromAddress = counterpartyAddress(s, txFromWallet)
+			}
+
+		case model.TxTypeBridge:
+			fromWallet, err := s.GetWallet(txFromWallet)
+			if err != nil {
+				return nil, fmt.Errorf("Source wallet '%s' not found: specify it with --from", txFromWallet)
+			}
+			toWallet, err := s.GetWallet(txToWallet)
+			if err != nil {
+				return nil, fmt.Errorf("Destination wallet '%s' not found: specify it with --to", txToWallet)
+			}
+			fromAddress, toAddress = fromWallet.Address, toWallet.Address
+			if txFromChain == "" {
+				txFromChain = fromWallet.Chain
+			}
+			if txToChain == "" {
+				txToChain = toWallet.Chain
+			}
+			if txReceivedAmount.IsZero() {
+				txReceivedAmount = txAmount
+			}
+			if txReceivedCoin == txCoin {
+				txReceivedCoin = ""
+			}
+
+		case model.TxTypeLPAdd, model.TxTypeLPRemove:
+			wallet, err := s.GetWallet(txFromWallet)
+			if err != nil {
+				return nil, fmt.Errorf("Wallet '%s' not found: specify it with --from", txFromWallet)
+			}
+			fromAddress = wallet.Address
+			if txPool == "" || txPairCoin == "" || !txLPTokens.IsPositive() {
+				return nil, errors.New("Liquidity transactions need --pool, --pair-coin, --pair-amount and --lp-tokens")
+			}
+
+		case model.TxTypeMulti:
+			if legs, err = parseLegs(strings.Join(txLegs, ",")); err != nil {
+				return nil, err
+			}
+
+		default:
+			names := make([]string, len(explicitTxTypes))
+			for i, t := range explicitTxTypes {
+				names[i] = string(t)
+			}
+			return nil, fmt.Errorf("Unknown transaction type '%s': use one of %s", txKind, strings.Join(names, ", "))
+		}
+
+	} else if txSwapWallet != "" {
 		// Handle swap transaction
 		txType = model.TxTypeSwap
 		
 		// Verify wallet exists
 		_, err := s.GetWallet(txSwapWallet)
 		if err != nil {
-			er(fmt.Sprintf("Swap wallet '%s' not found", txSwapWallet))
-			return
+			return nil, fmt.Errorf("Swap wallet '%s' not found", txSwapWallet)
 		}
 		
 	} else if txFromWallet != "" && txToWallet != "" {
@@ -134,8 +415,7 @@ func addTransaction(cmd *cobra.Command, args []string) {
 			// Check if it's a contact
 			contact, err := s.GetContact(txFromWallet)
 			if err != nil {
-				er(fmt.Sprintf("Source wallet or contact '%s' not found", txFromWallet))
-				return
+				return nil, fmt.Errorf("Source wallet or contact '%s' not found", txFromWallet)
 			}
 			fromAddress = contact.Address
 			// Keep txFromWallet as the contact name
@@ -148,8 +428,7 @@ func addTransaction(cmd *cobra.Command, args []string) {
 			// Check if it's a contact
 			contact, err := s.GetContact(txToWallet)
 			if err != nil {
-				er(fmt.Sprintf("Destination wallet or contact '%s' not found", txToWallet))
-				return
+				return nil, fmt.Errorf("Destination wallet or contact '%s' not found", txToWallet)
 			}
 			toAddress = contact.Address
 			// Keep txToWallet as the contact name
@@ -159,8 +438,7 @@ func addTransaction(cmd *cobra.Command, args []string) {
 
 		// If both are contacts, that's invalid
 		if fromWallet == nil && toWallet == nil {
-			er("Cannot transfer between two contacts")
-			return
+			return nil, errors.New("Cannot transfer between two contacts")
 		}
 
 	} else if txFromWallet != "" {
@@ -170,8 +448,7 @@ func addTransaction(cmd *cobra.Command, args []string) {
 		// Verify wallet exists
 		fromWallet, err := s.GetWallet(txFromWallet)
 		if err != nil {
-			er(fmt.Sprintf("Source wallet '%s' not found", txFromWallet))
-			return
+			return nil, fmt.Errorf("Source wallet '%s' not found", txFromWallet)
 		}
 		fromAddress = fromWallet.Address
 
@@ -193,8 +470,7 @@ func addTransaction(cmd *cobra.Command, args []string) {
 		// Verify wallet exists
 		toWallet, err := s.GetWallet(txToWallet)
 		if err != nil {
-			er(fmt.Sprintf("Destination wallet '%s' not found", txToWallet))
-			return
+			return nil, fmt.Errorf("Destination wallet '%s' not found", txToWallet)
 		}
 		toAddress = toWallet.Address
 
@@ -211,8 +487,7 @@ func addTransaction(cmd *cobra.Command, args []string) {
 	}
 
 	// Create and add the transaction
-	tx := &model.Tx{
-		ID:          s.GenerateTxID(),
+	return &model.Tx{
 		Type:        txType,
 		FromWallet:  txFromWallet,
 		ToWallet:    txToWallet,
@@ -221,21 +496,272 @@ func addTransaction(cmd *cobra.Command, args []string) {
 		Coin:        txCoin,
 		Amount:      txAmount,
 		Fee:         txFee,
+		FeeCoin:     txFeeCoin,
+		FeeWallet:   txFeeWallet,
 		SwapWallet:  txSwapWallet,
 		SellCoin:    txSellCoin,
 		SellAmount:  txSellAmount,
 		BuyCoin:     txBuyCoin,
 		BuyAmount:   txBuyAmount,
-		Date:        time.Now(),
+		Date:        date,
+		Hash:        txHash,
 		Note:        txNote,
+
+		FromChain:      txFromChain,
+		ToChain:        txToChain,
+		Protocol:       txProtocol,
+		ReceivedCoin:   txReceivedCoin,
+		ReceivedAmount: txReceivedAmount,
+		Legs:           legs,
+
+		Pool:       txPool,
+		PairCoin:   txPairCoin,
+		PairAmount: txPairAmount,
+		LPTokens:   txLPTokens,
+		CostBasis:  txCostBasis,
+
+		Tags: txTags,
+	}, nil
+}
+
+func importTransactions(cmd *cobra.Command, args []string) {
+	raw, err := os.ReadFile(args[0])
+	if err != nil {
+		er(fmt.Sprintf("Failed to read import file: %v", err))
+		return
+	}
+	var txs []*model.Tx
+	if err := json.Unmarshal(raw, &txs); err != nil {
+		er(fmt.Sprintf("Failed to parse import file: %v", err))
+		return
 	}
 
-	if err := s.AddTransaction(tx); err != nil {
-		er(fmt.Sprintf("Failed to add transaction: %v", err))
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
-	fmt.Printf("Transaction added successfully\n")
+	added, skipped := 0, 0
+	err = s.Update(func(batch storage.Store) error {
+		for i, tx := range txs {
+			storage.NormalizeCoins(tx)
+			switch {
+			case txNewID:
+				tx.ID = fmt.Sprintf("%s_%d", batch.GenerateTxID(), i)
+			case tx.ID == "":
+				tx.ID = storage.ContentTxID(tx)
+			}
+
+			if err := batch.AddTransaction(tx); err != nil {
+				if errors.Is(err, storage.ErrTxExists) {
+					skipped++
+					continue
+				}
+				return fmt.Errorf("transaction %d: %w", i+1, err)
+			}
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		er(fmt.Sprintf("Failed to import transactions: %v", err))
+		return
+	}
+
+	fmt.Printf("Imported %d transaction(s), skipped %d already recorded\n", added, skipped)
+}
+
+func editTransaction(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	stored, err := s.GetTransaction(args[0])
+	if err != nil {
+		er(fmt.Sprintf("Failed to get transaction: %v", err))
+		return
+	}
+
+	// Edit a copy so storage still knows the old wallets
+	tx := *stored
+	changed := 0
+	for _, field := range txFields {
+		flag := cmd.Flags().Lookup(field)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		if err := setTxField(s, &tx, field, flag.Value.String()); err != nil {
+			er(err.Error())
+			return
+		}
+		changed++
+	}
+	if changed == 0 {
+		er("Nothing to change: pass the fields to edit as flags")
+		return
+	}
+
+	if err := s.UpdateTransaction(&tx); err != nil {
+		er(fmt.Sprintf("Failed to edit transaction: %v", err))
+		return
+	}
+
+	fmt.Printf("Transaction %s updated successfully\n", tx.ID)
+}
+
+// txFields are the transaction fields tx edit and the palette can set
+var txFields = []string{"from", "to", "swap", "coin", "amount", "fee", "fee-coin", "fee-wallet", "sell-coin", "sell-amount", "buy-coin", "buy-amount", "from-chain", "to-chain", "protocol", "received-coin", "received-amount", "legs", "pool", "pair-coin", "pair-amount", "lp-tokens", "cost-basis", "date", "note", "hash", "tags"}
+
+// setTxField sets one field of tx from its text form. Changing a wallet
+// also updates the address recorded for it.
+func setTxField(s storage.Store, tx *model.Tx, field, value string) error {
+	if (field == "from" || field == "to") && tx.Type == model.TxTypeTransfer {
+		_, walletErr := s.GetWallet(value)
+		_, contactErr := s.GetContact(value)
+		if walletErr != nil && contactErr != nil {
+			return fmt.Errorf("wallet or contact '%s' not found", value)
+		}
+	}
+
+	var err error
+	switch field {
+	case "from":
+		tx.FromWallet, tx.FromAddress = value, counterpartyAddress(s, value)
+	case "to":
+		tx.ToWallet, tx.ToAddress = value, counterpartyAddress(s, value)
+	case "swap":
+		tx.SwapWallet = value
+	case "coin":
+		tx.Coin = strings.ToUpper(value)
+	case "sell-coin":
+		tx.SellCoin = strings.ToUpper(value)
+	case "buy-coin":
+		tx.BuyCoin = strings.ToUpper(value)
+	case "amount":
+		tx.Amount, err = util.ParseAmount(value)
+	case "fee":
+		tx.Fee, err = util.ParseAmount(value)
+	case "fee-coin":
+		tx.FeeCoin = strings.ToUpper(value)
+	case "fee-wallet":
+		tx.FeeWallet = value
+	case "sell-amount":
+		tx.SellAmount, err = util.ParseAmount(value)
+	case "buy-amount":
+		tx.BuyAmount, err = util.ParseAmount(value)
+	case "from-chain":
+		tx.FromChain = value
+	case "to-chain":
+		tx.ToChain = value
+	case "protocol":
+		tx.Protocol = value
+	case "received-coin":
+		tx.ReceivedCoin = strings.ToUpper(value)
+	case "received-amount":
+		tx.ReceivedAmount, err = util.ParseAmount(value)
+	case "legs":
+		tx.Legs, err = parseLegs(value)
+	case "pool":
+		tx.Pool = value
+	case "pair-coin":
+		tx.PairCoin = strings.ToUpper(value)
+	case "pair-amount":
+		tx.PairAmount, err = util.ParseAmount(value)
+	case "lp-tokens":
+		tx.LPTokens, err = util.ParseAmount(value)
+	case "cost-basis":
+		tx.CostBasis, err = util.ParseAmount(value)
+	case "date":
+		tx.Date, err = util.ParseDate(value)
+	case "note":
+		tx.Note = value
+	case "hash":
+		tx.Hash = value
+	case "tags":
+		tx.Tags, err = storage.NormalizeTags(strings.Split(value, ","))
+	default:
+		return fmt.Errorf("unknown field '%s' (use %s)", field, strings.Join(txFields, ", "))
+	}
+	return err
+}
+
+// printLegs prints the legs of a multi-leg transaction, one per line
+func printLegs(legs []model.Leg, indent string) {
+	width := 0
+	for _, leg := range legs {
+		if len(leg.Wallet) > width {
+			width = len(leg.Wallet)
+		}
+	}
+	for _, leg := range legs {
+		amount := color.GreenString("+%s", leg.Amount.StringFixed(2))
+		if leg.Amount.IsNegative() {
+			amount = color.RedString("%s", leg.Amount.StringFixed(2))
+		}
+		fmt.Printf("%s%-*s %s %s\n", indent, width, leg.Wallet, amount, color.New(color.Bold).Sprint(leg.Coin))
+	}
+}
+
+// parseLegs parses comma-separated WALLET:AMOUNT:COIN legs
+func parseLegs(value string) ([]model.Leg, error) {
+	var legs []model.Leg
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.Split(spec, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid leg '%s': use WALLET:AMOUNT:COIN", spec)
+		}
+		amount, err := util.ParseAmount(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("leg '%s': %v", spec, err)
+		}
+		legs = append(legs, model.Leg{Wallet: parts[0], Coin: strings.ToUpper(parts[2]), Amount: amount})
+	}
+	if len(legs) == 0 {
+		return nil, fmt.Errorf("no legs given: use WALLET:AMOUNT:COIN")
+	}
+	return legs, nil
+}
+
+// lpDetails describes the second coin and pool of a liquidity transaction
+func lpDetails(tx *model.Tx) string {
+	if tx.Type == model.TxTypeLPAdd {
+		return fmt.Sprintf("+ %s %s from %s into %s for %s LP", tx.PairAmount.StringFixed(2), tx.PairCoin, tx.FromWallet, tx.Pool, tx.LPTokens)
+	}
+	return fmt.Sprintf("+ %s %s to %s from %s for %s LP", tx.PairAmount.StringFixed(2), tx.PairCoin, tx.FromWallet, tx.Pool, tx.LPTokens)
+}
+
+// bridgeDetails describes where a bridge went and what arrived
+func bridgeDetails(tx *model.Tx) string {
+	coin, amount := storage.BridgeReceived(tx)
+	details := fmt.Sprintf("from %s (%s) to %s (%s)", tx.FromWallet, tx.FromChain, tx.ToWallet, tx.ToChain)
+	if tx.Protocol != "" {
+		details += " via " + tx.Protocol
+	}
+	if coin != tx.Coin || !amount.Equal(tx.Amount) {
+		details += fmt.Sprintf(", received %s %s", amount.StringFixed(2), coin)
+	}
+	return details
+}
+
+// counterpartyAddress returns the address of a wallet or contact, or the
+// name itself when it is neither (withdrawals may name a raw address)
+func counterpartyAddress(s storage.Store, name string) string {
+	if wallet, err := s.GetWallet(name); err == nil {
+		return wallet.Address
+	}
+	if contact, err := s.GetContact(name); err == nil {
+		return contact.Address
+	}
+	return name
 }
 
 func deleteTransaction(cmd *cobra.Command, args []string) {
@@ -244,6 +770,7 @@ func deleteTransaction(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	txID := args[1]
 
@@ -261,6 +788,7 @@ func listTransactions(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	// Get all wallets to access their transactions
 	wallets := s.ListWallets()
@@ -271,6 +799,14 @@ func listTransactions(cmd *cobra.Command, args []string) {
 
 	// Get all transactions from storage
 	allTxs := s.ListTransactions()
+	tags, err := storage.NormalizeTags(txFilterTags)
+	if err != nil {
+		er(err.Error())
+		return
+	}
+	if len(tags) > 0 {
+		allTxs = filterByTags(allTxs, tags)
+	}
 	
 	// Build wallet map for reference
 	walletMap := make(map[string]*model.Wallet)
@@ -285,7 +821,14 @@ func listTransactions(cmd *cobra.Command, args []string) {
 
 	// Print transactions with enhanced formatting
 	titleColor := color.New(color.Bold, color.Underline)
-	titleColor.Println("Recent Transactions:")
+	if len(tags) > 0 {
+		titleColor.Printf("Recent Transactions (%s):\n", formatTags(tags))
+	} else {
+		titleColor.Println("Recent Transactions:")
+	}
+	if len(allTxs) == 0 {
+		fmt.Println("  No transactions found")
+	}
 
 	for _, tx := range allTxs {
 		// Create colored elements for transaction
@@ -309,6 +852,29 @@ func listTransactions(cmd *cobra.Command, args []string) {
 			txTypeColor = color.New(color.FgMagenta, color.Bold)
 			// For swaps, we'll show a special format
 			amountPrefix = ""
+		case model.TxTypeStake, model.TxTypeUnstake:
+			// Staking only moves funds within the wallet
+			txTypeColor = color.New(color.FgCyan, color.Bold)
+			amountColor = color.New(color.FgCyan)
+			amountPrefix = ""
+		case model.TxTypeReward:
+			txTypeColor = color.New(color.FgHiGreen, color.Bold)
+		case model.TxTypeAirdrop:
+			txTypeColor = color.New(color.FgHiCyan, color.Bold)
+		case model.TxTypeIncome:
+			txTypeColor = color.New(color.FgHiBlue, color.Bold)
+		case model.TxTypeBridge:
+			txTypeColor = color.New(color.FgHiYellow, color.Bold)
+			amountColor = color.New(color.FgHiYellow)
+			amountPrefix = ""
+		case model.TxTypeMulti:
+			txTypeColor = color.New(color.FgBlue, color.Bold)
+		case model.TxTypeLPAdd:
+			txTypeColor = color.New(color.FgHiMagenta, color.Bold)
+			amountColor = color.New(color.FgRed)
+			amountPrefix = "-"
+		case model.TxTypeLPRemove:
+			txTypeColor = color.New(color.FgHiMagenta, color.Bold)
 		}
 		
 		// Format transaction details
@@ -324,15 +890,15 @@ func listTransactions(cmd *cobra.Command, args []string) {
 			sellColor := color.New(color.FgRed)
 			buyColor := color.New(color.FgGreen)
 			coloredAmount = fmt.Sprintf("%s %s → %s %s",
-				sellColor.Sprintf("-%.2f", tx.SellAmount),
+				sellColor.Sprintf("-%s", tx.SellAmount.StringFixed(2)),
 				color.New(color.Bold).Sprint(tx.SellCoin),
-				buyColor.Sprintf("+%.2f", tx.BuyAmount),
+				buyColor.Sprintf("+%s", tx.BuyAmount.StringFixed(2)),
 				color.New(color.Bold).Sprint(tx.BuyCoin))
 			coloredCoin = ""
 			details = fmt.Sprintf("in %s", tx.SwapWallet)
 		default:
 			// Standard formatting for other transaction types
-			coloredAmount = amountColor.Sprintf("%s%.2f", amountPrefix, tx.Amount)
+			coloredAmount = amountColor.Sprintf("%s%s", amountPrefix, tx.Amount.StringFixed(2))
 			coloredCoin = color.New(color.Bold).Sprint(tx.Coin)
 			
 			switch tx.Type {
@@ -342,6 +908,17 @@ func listTransactions(cmd *cobra.Command, args []string) {
 				details = fmt.Sprintf("from %s", tx.FromWallet)
 			case model.TxTypeTransfer:
 				details = fmt.Sprintf("from %s to %s", tx.FromWallet, tx.ToWallet)
+			case model.TxTypeStake, model.TxTypeUnstake:
+				details = fmt.Sprintf("in %s", tx.FromWallet)
+			case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+				details = fmt.Sprintf("to %s", tx.ToWallet)
+				if tx.FromWallet != "" {
+					details = fmt.Sprintf("from %s to %s", tx.FromWallet, tx.ToWallet)
+				}
+			case model.TxTypeBridge:
+				details = bridgeDetails(tx)
+			case model.TxTypeLPAdd, model.TxTypeLPRemove:
+				details = lpDetails(tx)
 			}
 		}
 		
@@ -351,8 +928,9 @@ func listTransactions(cmd *cobra.Command, args []string) {
 		
 		// Format fee if present
 		feeStr := ""
-		if tx.Fee > 0 {
-			feeStr = color.New(color.FgHiBlack).Sprintf(" [fee: %.2f %s]", tx.Fee, tx.Coin)
+		if tx.Fee.IsPositive() {
+			feeWallet, feeCoin := storage.FeePayer(tx)
+			feeStr = color.New(color.FgHiBlack).Sprintf(" [fee: %s %s from %s]", tx.Fee.String(), feeCoin, feeWallet)
 		}
 
 		// Format note with color if present
@@ -360,9 +938,21 @@ func listTransactions(cmd *cobra.Command, args []string) {
 		if tx.Note != "" {
 			noteStr = color.New(color.FgYellow).Sprintf(" \"%s\"", tx.Note)
 		}
+		if len(tx.Tags) > 0 {
+			noteStr += color.New(color.FgCyan).Sprintf(" %s", formatTags(tx.Tags))
+		}
 		
 		// Print the transaction with all the colored elements
-		if tx.Type == model.TxTypeSwap {
+		if tx.Type == model.TxTypeMulti {
+			// Legs are listed under the transaction ID
+			fmt.Printf("  %s %s %s%s%s\n",
+				coloredType,
+				color.New(color.FgHiBlack).Sprint(tx.ID),
+				dateStr,
+				feeStr,
+				noteStr)
+			printLegs(tx.Legs, "      ")
+		} else if tx.Type == model.TxTypeSwap {
 			// For swap transactions, coloredCoin is empty so we skip it
 			fmt.Printf("  %s %s %s %s%s%s\n", 
 				coloredType,
@@ -383,4 +973,60 @@ func listTransactions(cmd *cobra.Command, args []string) {
 				noteStr)
 		}
 	}
+
+	// Summarize the fees paid, per coin
+	totals := make(map[string]decimal.Decimal)
+	for _, coins := range sumFees(allTxs) {
+		for coin, total := range coins {
+			totals[coin] = totals[coin].Add(total)
+		}
+	}
+	if len(totals) > 0 {
+		coins := make([]string, 0, len(totals))
+		for coin := range totals {
+			coins = append(coins, coin)
+		}
+		sort.Strings(coins)
+		parts := make([]string, 0, len(coins))
+		for _, coin := range coins {
+			parts = append(parts, fmt.Sprintf("%s %s", totals[coin].String(), color.New(color.Bold).Sprint(coin)))
+		}
+		fmt.Printf("\nFees paid: %s\n", strings.Join(parts, ", "))
+	}
+}
+
+// filterByTags keeps the transactions carrying every one of tags
+func filterByTags(txs []*model.Tx, tags []string) []*model.Tx {
+	var filtered []*model.Tx
+	for _, tx := range txs {
+		if storage.HasTags(tx, tags) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// formatTags renders tags as "#a #b"
+func formatTags(tags []string) string {
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		parts[i] = "#" + tag
+	}
+	return strings.Join(parts, " ")
+}
+
+// sumFees totals the fees of txs per paying wallet and coin
+func sumFees(txs []*model.Tx) map[string]map[string]decimal.Decimal {
+	fees := make(map[string]map[string]decimal.Decimal)
+	for _, tx := range txs {
+		if tx.Fee.IsZero() {
+			continue
+		}
+		wallet, coin := storage.FeePayer(tx)
+		if fees[wallet] == nil {
+			fees[wallet] = make(map[string]decimal.Decimal)
+		}
+		fees[wallet][coin] = fees[wallet][coin].Add(tx.Fee)
+	}
+	return fees
 }
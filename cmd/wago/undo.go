@@ -0,0 +1,64 @@
+package wago
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vasylcode/wago/internal/storage"
+)
+
+func init() {
+	// Undo command
+	undoCmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Undo the last change",
+		Long: `Revert the last change to the ledger. The history is kept per profile in
+history.json and survives restarts.`,
+		Args: cobra.NoArgs,
+		Run:  undoChange,
+	}
+
+	// Redo command
+	redoCmd := &cobra.Command{
+		Use:   "redo",
+		Short: "Redo the last undone change",
+		Long:  `Reapply the last change reverted with undo.`,
+		Args:  cobra.NoArgs,
+		Run:   redoChange,
+	}
+
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(redoCmd)
+}
+
+func undoChange(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	summary, err := s.Undo()
+	if err != nil {
+		er(fmt.Sprintf("Failed to undo: %v", err))
+		return
+	}
+	fmt.Printf("Undone: %s\n", summary)
+}
+
+func redoChange(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	summary, err := s.Redo()
+	if err != nil {
+		er(fmt.Sprintf("Failed to redo: %v", err))
+		return
+	}
+	fmt.Printf("Redone: %s\n", summary)
+}
@@ -19,6 +19,9 @@ var (
 	walletNote     string
 	showBalances   bool
 	showTxs        bool
+	showArchived   bool
+	walletCascade  bool
+	walletArchive  bool
 )
 
 func init() {
@@ -27,7 +30,7 @@ func init() {
 		Use:     "wallet",
 		Aliases: []string{"w"},
 		Short:   "Manage wallets",
-		Long:    `Add, delete, update, and list wallets.`,
+		Long:    `Add, delete, update, rename, archive, and list wallets.`,
 		Run:     listWallets,
 	}
 
@@ -44,11 +47,22 @@ func init() {
 	delWalletCmd := &cobra.Command{
 		Use:   "del [name]",
 		Short: "Delete a wallet",
-		Long:  `Delete a wallet and all its transactions and balances.`,
+		Long: `Delete a wallet. A wallet with transactions is only deleted with
+--cascade, which also deletes its transactions and reverses their effect on
+counterpart wallets, or hidden with --archive, which keeps its history.`,
 		Args:  cobra.ExactArgs(1),
 		Run:   deleteWallet,
 	}
 
+	// Rename subcommand
+	renWalletCmd := &cobra.Command{
+		Use:   "rename [old] [new]",
+		Short: "Rename a wallet",
+		Long:  `Rename a wallet and update every transaction that references it.`,
+		Args:  cobra.ExactArgs(2),
+		Run:   renameWallet,
+	}
+
 	// Update subcommand
 	updWalletCmd := &cobra.Command{
 		Use:   "upd [name]",
@@ -76,14 +90,27 @@ func init() {
 	updWalletCmd.Flags().StringVarP(&walletType, "type", "t", "", "Wallet type")
 	updWalletCmd.Flags().StringVarP(&walletNote, "note", "", "", "Note to describe wallet")
 
+	// Add flags to delete command
+	delWalletCmd.Flags().BoolVar(&walletCascade, "cascade", false, "Also delete the wallet's transactions")
+	delWalletCmd.Flags().BoolVar(&walletArchive, "archive", false, "Archive the wallet instead of deleting it")
+	delWalletCmd.MarkFlagsMutuallyExclusive("cascade", "archive")
+
 	// Add flags to wallet list command
 	walletCmd.Flags().BoolVarP(&showBalances, "balances", "b", false, "Show wallet balances")
 	walletCmd.Flags().BoolVarP(&showTxs, "txs", "t", false, "Show wallet transactions")
+	walletCmd.Flags().BoolVar(&showArchived, "all", false, "Include archived wallets")
+
+	archiveWalletCmd, unarchiveWalletCmd := newArchiveCmds("wallet", func(s storage.Store, name string, archived bool) error {
+		return s.SetWalletArchived(name, archived)
+	})
 
 	// Add subcommands to wallet command
 	walletCmd.AddCommand(addWalletCmd)
 	walletCmd.AddCommand(delWalletCmd)
 	walletCmd.AddCommand(updWalletCmd)
+	walletCmd.AddCommand(renWalletCmd)
+	walletCmd.AddCommand(archiveWalletCmd)
+	walletCmd.AddCommand(unarchiveWalletCmd)
 
 	// Add wallet command to root command
 	rootCmd.AddCommand(walletCmd)
@@ -95,26 +122,10 @@ func addWallet(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	name := args[0]
 	
-	// Check if category exists, if not, create it
-	if walletCategory != "" {
-		_, err := s.GetCategory(walletCategory)
-		if err != nil {
-			// Category doesn't exist, create it with a random color
-			category := &model.Category{
-				Name:  walletCategory,
-				Color: generateRandomColor(),
-			}
-			if err := s.AddCategory(category); err != nil {
-				er(fmt.Sprintf("Failed to create category: %v", err))
-				return
-			}
-			fmt.Printf("Category '%s' created automatically with color %s\n", walletCategory, category.Color)
-		}
-	}
-	
 	wallet := &model.Wallet{
 		Name:     name,
 		Address:  walletAddress,
@@ -124,11 +135,31 @@ func addWallet(cmd *cobra.Command, args []string) {
 		Note:     walletNote,
 	}
 
-	if err := s.AddWallet(wallet); err != nil {
+	// Save the wallet and a missing category together
+	var category *model.Category
+	err = s.Update(func(tx storage.Store) error {
+		if walletCategory != "" {
+			if _, err := tx.GetCategory(walletCategory); err != nil {
+				// Category doesn't exist, create it with a random color
+				category = &model.Category{
+					Name:  walletCategory,
+					Color: generateRandomColor(),
+				}
+				if err := tx.AddCategory(category); err != nil {
+					return fmt.Errorf("failed to create category: %w", err)
+				}
+			}
+		}
+		return tx.AddWallet(wallet)
+	})
+	if err != nil {
 		er(fmt.Sprintf("Failed to add wallet: %v", err))
 		return
 	}
 
+	if category != nil {
+		fmt.Printf("Category '%s' created automatically with color %s\n", walletCategory, category.Color)
+	}
 	fmt.Printf("Wallet '%s' added successfully\n", name)
 }
 
@@ -138,22 +169,52 @@ func deleteWallet(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
+
+	policy := storage.DeleteRefuse
+	if walletCascade {
+		policy = storage.DeleteCascade
+	} else if walletArchive {
+		policy = storage.DeleteArchive
+	}
 
 	name := args[0]
-	if err := s.DeleteWallet(name); err != nil {
+	if err := s.DeleteWallet(name, policy); err != nil {
 		er(fmt.Sprintf("Failed to delete wallet: %v", err))
 		return
 	}
 
+	if policy == storage.DeleteArchive {
+		fmt.Printf("Wallet '%s' archived successfully\n", name)
+		return
+	}
 	fmt.Printf("Wallet '%s' deleted successfully\n", name)
 }
 
+func renameWallet(cmd *cobra.Command, args []string) {
+	s, err := storage.New()
+	if err != nil {
+		er(fmt.Sprintf("Failed to initialize storage: %v", err))
+		return
+	}
+	defer s.Close()
+
+	oldName, newName := args[0], args[1]
+	if err := s.RenameWallet(oldName, newName); err != nil {
+		er(fmt.Sprintf("Failed to rename wallet: %v", err))
+		return
+	}
+
+	fmt.Printf("Wallet '%s' renamed to '%s'\n", oldName, newName)
+}
+
 func updateWallet(cmd *cobra.Command, args []string) {
 	s, err := storage.New()
 	if err != nil {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	name := args[0]
 	wallet, err := s.GetWallet(name)
@@ -193,6 +254,7 @@ func listWallets(cmd *cobra.Command, args []string) {
 		er(fmt.Sprintf("Failed to initialize storage: %v", err))
 		return
 	}
+	defer s.Close()
 
 	// If a specific wallet name is provided, show that wallet
 	if len(args) == 1 {
@@ -200,8 +262,11 @@ func listWallets(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	// Otherwise, list all wallets
+	// Otherwise, list all wallets, archived ones only with --all
 	wallets := s.ListWallets()
+	if !showArchived {
+		wallets = activeWallets(wallets, s.ListCategories())
+	}
 	if len(wallets) == 0 {
 		fmt.Println("No wallets found")
 		return
@@ -227,11 +292,15 @@ func listWallets(cmd *cobra.Command, args []string) {
 		if showTxs {
 			txs = s.GetWalletTransactions(wallet.Name)
 		}
-		printWallet(wallet, categoryColors, showBalances, showTxs, txs)
+		var positions []*model.Position
+		if showBalances {
+			positions = s.ListPositions(wallet.Name)
+		}
+		printWallet(wallet, categoryColors, showBalances, showTxs, txs, positions)
 	}
 }
 
-func showWallet(s *storage.Storage, name string) {
+func showWallet(s storage.Store, name string) {
 	wallet, err := s.GetWallet(name)
 	if err != nil {
 		er(fmt.Sprintf("Failed to get wallet: %v", err))
@@ -254,10 +323,10 @@ func showWallet(s *storage.Storage, name string) {
 
 	// Always show balances and transactions for a specific wallet
 	txs := s.GetWalletTransactions(wallet.Name)
-	printWallet(wallet, categoryColors, true, true, txs)
+	printWallet(wallet, categoryColors, true, true, txs, s.ListPositions(wallet.Name))
 }
 
-func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, showBalances, showTxs bool, txs []*model.Tx) {
+func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, showBalances, showTxs bool, txs []*model.Tx, positions []*model.Position) {
 	// Format the wallet information
 	catPrefix := ""
 	if wallet.Category != "" {
@@ -278,6 +347,9 @@ func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, s
 	if wallet.Note != "" {
 		noteStr = color.New(color.FgYellow).Sprintf(" (%s)", wallet.Note)
 	}
+	if wallet.Archived {
+		noteStr += color.New(color.FgHiBlack).Sprint(" [archived]")
+	}
 	
 	fmt.Printf("%s%s %s %s%s\n", 
 		catPrefix, 
@@ -301,24 +373,24 @@ func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, s
 		
 		for _, balance := range wallet.Balances {
 			// Round to 2 decimal places for display
-			displayAmount := fmt.Sprintf("%.2f", balance.Amount)
+			displayAmount := balance.Amount.StringFixed(2)
 			
 			// Color based on amount (green for positive, red for negative)
 			amountColor := color.New(color.FgGreen)
-			if balance.Amount < 0 {
+			if balance.Amount.IsNegative() {
 				amountColor = color.New(color.FgRed)
 			}
 			
 			coloredAmount := amountColor.Sprint(displayAmount)
-			coinName := color.New(color.Bold).Sprint(balance.Coin)
+			coinName := color.New(color.Bold).Sprint(balance.Label())
 			
 			// Add USD value if available
 			usdStr := ""
 			if err == nil {
 				if price, exists := prices[strings.ToLower(balance.Coin)]; exists {
-					usdValue := balance.Amount * price
+					usdValue := util.USDValue(balance.Amount, price)
 					usdColor := color.New(color.FgHiBlack)
-					if balance.Amount < 0 {
+					if balance.Amount.IsNegative() {
 						usdColor = color.New(color.FgRed)
 					}
 					usdStr = usdColor.Sprintf(" (%s)", util.FormatUSDValue(usdValue))
@@ -329,6 +401,19 @@ func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, s
 		}
 	}
 	
+	// Liquidity positions go with the balances
+	if showBalances && len(positions) > 0 {
+		fmt.Println("  Liquidity positions:")
+		coins := make([]string, 0, 2*len(positions))
+		for _, pos := range positions {
+			coins = append(coins, pos.Coin, pos.PairCoin)
+		}
+		prices, _ := util.GetCoinPrices(coins)
+		for _, pos := range positions {
+			printPosition(pos, prices, "    ")
+		}
+	}
+
 	// Show transactions if requested
 	if showTxs && len(txs) > 0 {
 		fmt.Println("  Transactions:")
@@ -343,6 +428,26 @@ func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, s
 				details = fmt.Sprintf("to %s", tx.ToAddress)
 			case model.TxTypeTransfer:
 				details = fmt.Sprintf("from %s to %s", tx.FromWallet, tx.ToWallet)
+			case model.TxTypeStake:
+				details = "to staked"
+			case model.TxTypeUnstake:
+				details = "from staked"
+			case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+				if tx.FromWallet != "" {
+					details = fmt.Sprintf("from %s", tx.FromWallet)
+				}
+			case model.TxTypeBridge:
+				details = fmt.Sprintf("from %s (%s) to %s (%s)", tx.FromWallet, tx.FromChain, tx.ToWallet, tx.ToChain)
+			case model.TxTypeMulti:
+				details = fmt.Sprintf("%s (%d legs)", tx.ID, len(tx.Legs))
+			case model.TxTypeLPAdd, model.TxTypeLPRemove:
+				details = lpDetails(tx)
+			}
+
+			// The receiving side of a bridge sees what arrived
+			amount, coin := tx.Amount, tx.Coin
+			if tx.Type == model.TxTypeBridge && tx.ToWallet == wallet.Name {
+				coin, amount = storage.BridgeReceived(tx)
 			}
 			
 			// Create colored elements for transaction
@@ -366,12 +471,52 @@ func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, s
 				} else {
 					amountColor = color.New(color.FgGreen)
 				}
+			case model.TxTypeStake, model.TxTypeUnstake:
+				txTypeColor = color.New(color.FgCyan, color.Bold)
+				amountColor = color.New(color.FgCyan)
+				amountPrefix = ""
+			case model.TxTypeReward:
+				txTypeColor = color.New(color.FgHiGreen, color.Bold)
+			case model.TxTypeAirdrop:
+				txTypeColor = color.New(color.FgHiCyan, color.Bold)
+			case model.TxTypeIncome:
+				txTypeColor = color.New(color.FgHiBlue, color.Bold)
+			case model.TxTypeBridge:
+				txTypeColor = color.New(color.FgHiYellow, color.Bold)
+				if tx.FromWallet == wallet.Name {
+					amountColor = color.New(color.FgRed)
+					amountPrefix = "-"
+				}
+			case model.TxTypeMulti:
+				txTypeColor = color.New(color.FgBlue, color.Bold)
+			case model.TxTypeLPAdd:
+				txTypeColor = color.New(color.FgHiMagenta, color.Bold)
+				amountColor = color.New(color.FgRed)
+				amountPrefix = "-"
+			case model.TxTypeLPRemove:
+				txTypeColor = color.New(color.FgHiMagenta, color.Bold)
 			}
 			
 			// Format amount with prefix and color, rounded to 2 decimals
-			coloredAmount := amountColor.Sprintf("%s%.2f", amountPrefix, tx.Amount)
+			coloredAmount := amountColor.Sprintf("%s%s", amountPrefix, amount.StringFixed(2))
 			coloredType := txTypeColor.Sprint(strings.ToUpper(txType))
-			coloredCoin := color.New(color.Bold).Sprint(tx.Coin)
+			coloredCoin := color.New(color.Bold).Sprint(coin)
+
+			// A multi-leg transaction shows this wallet's legs
+			if tx.Type == model.TxTypeMulti {
+				var parts []string
+				for _, leg := range tx.Legs {
+					if leg.Wallet != wallet.Name {
+						continue
+					}
+					if leg.Amount.IsNegative() {
+						parts = append(parts, color.RedString("%s", leg.Amount.StringFixed(2))+" "+color.New(color.Bold).Sprint(leg.Coin))
+					} else {
+						parts = append(parts, color.GreenString("+%s", leg.Amount.StringFixed(2))+" "+color.New(color.Bold).Sprint(leg.Coin))
+					}
+				}
+				coloredAmount, coloredCoin = strings.Join(parts, ", "), ""
+			}
 			
 			// Format details with colors
 			coloredDetails := color.New(color.FgHiBlack).Sprint(details)
@@ -384,6 +529,12 @@ func printWallet(wallet *model.Wallet, categoryColors map[string]*color.Color, s
 			if tx.Note != "" {
 				noteStr = color.New(color.FgYellow).Sprintf(" (%s)", tx.Note)
 			}
+			if len(tx.Tags) > 0 {
+				noteStr += color.New(color.FgCyan).Sprintf(" %s", formatTags(tx.Tags))
+			}
+			if feeWallet, feeCoin := storage.FeePayer(tx); !tx.Fee.IsZero() && feeWallet == wallet.Name {
+				noteStr = color.New(color.FgHiBlack).Sprintf(" [fee: %s %s]", tx.Fee.String(), feeCoin) + noteStr
+			}
 			
 			fmt.Printf("    %s: %s %s %s %s%s\n", 
 				coloredType, 
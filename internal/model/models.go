@@ -2,15 +2,30 @@ package model
 
 import (
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // Data represents the unified data structure stored in wago.json
 type Data struct {
-	Wallets      map[string]*Wallet   `json:"wallets"`
-	Categories   map[string]*Category `json:"categories"`
-	Contacts     map[string]*Contact  `json:"contacts"`
-	Transactions map[string]*Tx       `json:"transactions"`
-	Prices       map[string]float64   `json:"prices"`
+	SchemaVersion int                  `json:"schema_version"`
+	Wallets       map[string]*Wallet   `json:"wallets"`
+	Categories    map[string]*Category `json:"categories"`
+	Contacts      map[string]*Contact  `json:"contacts"`
+	Transactions  map[string]*Tx       `json:"transactions"`
+	Schedules     map[string]*Schedule `json:"schedules"`
+	Prices        map[string]float64   `json:"prices"`
+	PriceUpdated  map[string]time.Time `json:"price_updated,omitempty"` // When each price was last set
+}
+
+// Schedule is a recurring transaction. Its template's date is the first
+// occurrence; each occurrence is recorded as a copy of the template.
+type Schedule struct {
+	Name string     `json:"name"`
+	Rule string     `json:"rule"` // Interval like 1w or 3m, or a cron expression
+	Tx   *Tx        `json:"tx"`
+	End  *time.Time `json:"end,omitempty"`  // No occurrences after this
+	Last *time.Time `json:"last,omitempty"` // Latest occurrence recorded
 }
 
 // Wallet represents a crypto wallet
@@ -21,27 +36,40 @@ type Wallet struct {
 	Chain    string     `json:"chain"`
 	Type     string     `json:"type"`
 	Note     string     `json:"note,omitempty"`
+	Archived bool       `json:"archived,omitempty"` // Hidden, kept for its history
 	Balances []*Balance `json:"balances,omitempty"`
 }
 
-// Balance represents a token balance in a wallet
+// Balance represents a token balance in a wallet. Staked funds are kept
+// in a separate entry of the same coin.
 type Balance struct {
-	Coin   string  `json:"coin"`
-	Amount float64 `json:"amount"`
+	Coin   string          `json:"coin"`
+	Amount decimal.Decimal `json:"amount"`
+	Staked bool            `json:"staked,omitempty"`
+}
+
+// Label names the balance for display, e.g. "ETH" or "ETH (staked)"
+func (b *Balance) Label() string {
+	if b.Staked {
+		return b.Coin + " (staked)"
+	}
+	return b.Coin
 }
 
 // Category represents a wallet category with a color
 type Category struct {
-	Name  string `json:"name"`
-	Color string `json:"color"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Archived bool   `json:"archived,omitempty"` // Hides the category and its wallets
 }
 
 // Contact represents a contact in the address book
 type Contact struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
-	Chain   string `json:"chain"`
-	Note    string `json:"note,omitempty"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Chain    string `json:"chain"`
+	Note     string `json:"note,omitempty"`
+	Archived bool   `json:"archived,omitempty"` // Hidden, kept for its history
 }
 
 // TxType represents the type of transaction
@@ -52,24 +80,73 @@ const (
 	TxTypeWithdraw TxType = "withdraw"
 	TxTypeTransfer TxType = "transfer"
 	TxTypeSwap     TxType = "swap"
+	TxTypeStake    TxType = "stake"   // Moves Amount of FromWallet's Coin to its staked balance
+	TxTypeUnstake  TxType = "unstake" // Moves Amount of FromWallet's staked Coin back
+	TxTypeReward   TxType = "reward"  // Staking or protocol reward received by ToWallet
+	TxTypeAirdrop  TxType = "airdrop"
+	TxTypeIncome   TxType = "income"
+	TxTypeBridge   TxType = "bridge"    // Moves funds from FromWallet on FromChain to ToWallet on ToChain
+	TxTypeMulti    TxType = "multi"     // Moves every leg in Legs at once
+	TxTypeLPAdd    TxType = "lp-add"    // Moves Coin and PairCoin of FromWallet into the Pool position
+	TxTypeLPRemove TxType = "lp-remove" // Burns LPTokens of the Pool position, returning Coin and PairCoin
 )
 
+// Leg is one movement of a multi-leg transaction. Amount is negative when
+// the asset leaves Wallet, which may also name a contact.
+type Leg struct {
+	Wallet string          `json:"wallet"`
+	Coin   string          `json:"coin"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Position is a wallet's liquidity pool position, replayed from its lp-add
+// and lp-remove transactions. The amounts are the deposited underlying
+// assets still attributed to the position.
+type Position struct {
+	Wallet     string
+	Pool       string
+	Coin       string
+	Amount     decimal.Decimal
+	PairCoin   string
+	PairAmount decimal.Decimal
+	LPTokens   decimal.Decimal
+	CostBasis  decimal.Decimal // USD
+}
+
 // Tx represents a transaction
 type Tx struct {
-	ID          string    `json:"id"`
-	Type        TxType    `json:"type"`
-	FromWallet  string    `json:"from_wallet,omitempty"`
-	ToWallet    string    `json:"to_wallet,omitempty"`
-	FromAddress string    `json:"from_address,omitempty"`
-	ToAddress   string    `json:"to_address,omitempty"`
-	Coin        string    `json:"coin"`
-	Amount      float64   `json:"amount"`
-	Fee         float64   `json:"fee,omitempty"`
-	SwapWallet  string    `json:"swap_wallet,omitempty"`
-	SellCoin    string    `json:"sell_coin,omitempty"`
-	SellAmount  float64   `json:"sell_amount,omitempty"`
-	BuyCoin     string    `json:"buy_coin,omitempty"`
-	BuyAmount   float64   `json:"buy_amount,omitempty"`
-	Date        time.Time `json:"date"`
-	Note        string    `json:"note,omitempty"`
+	ID          string          `json:"id"`
+	Type        TxType          `json:"type"`
+	FromWallet  string          `json:"from_wallet,omitempty"`
+	ToWallet    string          `json:"to_wallet,omitempty"`
+	FromAddress string          `json:"from_address,omitempty"`
+	ToAddress   string          `json:"to_address,omitempty"`
+	Coin        string          `json:"coin"`
+	Amount      decimal.Decimal `json:"amount"`
+	Fee         decimal.Decimal `json:"fee"`
+	FeeCoin     string          `json:"fee_coin,omitempty"`   // Defaults to the coin sent
+	FeeWallet   string          `json:"fee_wallet,omitempty"` // Defaults to the sending wallet
+	SwapWallet  string          `json:"swap_wallet,omitempty"`
+	SellCoin    string          `json:"sell_coin,omitempty"`
+	SellAmount  decimal.Decimal `json:"sell_amount"`
+	BuyCoin     string          `json:"buy_coin,omitempty"`
+	BuyAmount   decimal.Decimal `json:"buy_amount"`
+	// For bridges
+	FromChain      string          `json:"from_chain,omitempty"`
+	ToChain        string          `json:"to_chain,omitempty"`
+	Protocol       string          `json:"protocol,omitempty"`
+	ReceivedCoin   string          `json:"received_coin,omitempty"` // Defaults to Coin
+	ReceivedAmount decimal.Decimal `json:"received_amount"`
+	Legs           []Leg           `json:"legs,omitempty"` // For multi-leg transactions
+	// For liquidity pool positions
+	Pool       string          `json:"pool,omitempty"`
+	PairCoin   string          `json:"pair_coin,omitempty"`
+	PairAmount decimal.Decimal `json:"pair_amount"`
+	LPTokens   decimal.Decimal `json:"lp_tokens"`
+	CostBasis  decimal.Decimal `json:"cost_basis"` // USD value deposited by an lp-add
+	Date       time.Time       `json:"date"`
+	Hash       string          `json:"hash,omitempty"` // On-chain transaction hash
+	Note       string          `json:"note,omitempty"`
+	Tags       []string        `json:"tags,omitempty"`     // Lowercase, without the leading #
+	Schedule   string          `json:"schedule,omitempty"` // Schedule that recorded the transaction
 }
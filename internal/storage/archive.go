@@ -0,0 +1,61 @@
+package storage
+
+import "fmt"
+
+// SetWalletArchived archives or restores a wallet. Archived wallets keep
+// their transactions and still count in historical views.
+func (s *Storage) SetWalletArchived(name string, archived bool) error {
+	wallet, exists := s.data.Wallets[name]
+	if !exists {
+		return fmt.Errorf("wallet with name '%s' not found", name)
+	}
+	if err := checkArchived("wallet", name, wallet.Archived, archived); err != nil {
+		return err
+	}
+
+	wallet.Archived = archived
+	s.touch(kindWallets, name)
+	return s.save()
+}
+
+// SetContactArchived archives or restores a contact
+func (s *Storage) SetContactArchived(name string, archived bool) error {
+	contact, exists := s.data.Contacts[name]
+	if !exists {
+		return fmt.Errorf("contact with name '%s' not found", name)
+	}
+	if err := checkArchived("contact", name, contact.Archived, archived); err != nil {
+		return err
+	}
+
+	contact.Archived = archived
+	s.touch(kindContacts, name)
+	return s.save()
+}
+
+// SetCategoryArchived archives or restores a category. The wallets of an
+// archived category are hidden along with it.
+func (s *Storage) SetCategoryArchived(name string, archived bool) error {
+	category, exists := s.data.Categories[name]
+	if !exists {
+		return fmt.Errorf("category with name '%s' not found", name)
+	}
+	if err := checkArchived("category", name, category.Archived, archived); err != nil {
+		return err
+	}
+
+	category.Archived = archived
+	s.touch(kindCategories, name)
+	return s.save()
+}
+
+// checkArchived refuses a state change that would not change anything
+func checkArchived(kind, name string, current, archived bool) error {
+	if current == archived {
+		if archived {
+			return fmt.Errorf("%s '%s' is already archived", kind, name)
+		}
+		return fmt.Errorf("%s '%s' is not archived", kind, name)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// BackendJSON keeps the whole ledger in a single wago.json file
+	BackendJSON = "json"
+	// BackendSQLite keeps one row per wallet, transaction, price, ... in wago.db
+	BackendSQLite = "sqlite"
+
+	metaKind = "meta"
+
+	kindWallets      = "wallets"
+	kindCategories   = "categories"
+	kindContacts     = "contacts"
+	kindTransactions = "transactions"
+	kindSchedules    = "schedules"
+	kindPrices       = "prices"
+	kindPriceUpdated = "price_updated"
+)
+
+// backend is the persistence half of a Store. JSONStore and SQLiteStore
+// implement it on top of the Storage they embed, which holds all data in
+// memory and hands them the entities changed by each save.
+type backend interface {
+	Store
+
+	path() string
+
+	// read returns the stored ledger as a wago.json document, or nil if
+	// nothing has been stored yet
+	read() ([]byte, error)
+
+	// write persists the changed entities
+	write(changes []entityChange) error
+
+	// incremental reports whether write only touches changed entities
+	incremental() bool
+
+	// loadHistory and writeHistory read and replace the undo history;
+	// pushHistory records one more mutation
+	loadHistory() (*history, error)
+	writeHistory(h *history) error
+	pushHistory(entry historyEntry) error
+
+	closeBackend() error
+}
+
+// openBackend opens the store whose data file exists in the data directory
+// of s, defaulting to JSON for new ledgers
+func openBackend(s *Storage) (backend, error) {
+	if file := filepath.Join(s.dataDir, sqliteFileName); fileExists(file) {
+		b, err := openSQLiteStore(s, file)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.importHistoryFile(); err != nil {
+			b.closeBackend()
+			return nil, err
+		}
+		return b, nil
+	}
+	return newJSONStore(s, filepath.Join(s.dataDir, dataFileName)), nil
+}
+
+// newBackend creates an empty store of the given kind for s, refusing to
+// touch an existing data file
+func newBackend(kind string, s *Storage) (backend, error) {
+	var file string
+	switch kind {
+	case BackendJSON:
+		file = filepath.Join(s.dataDir, dataFileName)
+	case BackendSQLite:
+		file = filepath.Join(s.dataDir, sqliteFileName)
+	default:
+		return nil, fmt.Errorf("unknown backend '%s' (use %s or %s)", kind, BackendJSON, BackendSQLite)
+	}
+
+	if fileExists(file) {
+		return nil, fmt.Errorf("%s already exists; move it away first", file)
+	}
+
+	if kind == BackendSQLite {
+		return openSQLiteStore(s, file)
+	}
+	return newJSONStore(s, file), nil
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// MigrateBackend copies the ledger and its undo history to another store.
+// The old data file is kept with a ".migrated-<time>" suffix. The store is
+// closed afterwards; open the ledger again to use the new one.
+func (s *Storage) MigrateBackend(kind string) error {
+	if s.batch {
+		return errBatchInProgress
+	}
+
+	if s.lock == nil {
+		return fmt.Errorf("storage is closed")
+	}
+	if kind == s.backend.Backend() {
+		return fmt.Errorf("ledger already uses the %s backend", kind)
+	}
+	if s.key != nil && kind != BackendJSON {
+		return fmt.Errorf("encryption is only supported by the %s backend; run 'wago decrypt' first", BackendJSON)
+	}
+
+	h, err := s.backend.loadHistory()
+	if err != nil {
+		return err
+	}
+
+	// The new store gets its own view of the same ledger
+	target, err := newBackend(kind, &Storage{dataDir: s.dataDir, data: s.data, key: s.key})
+	if err != nil {
+		return err
+	}
+	doc, err := json.Marshal(s.data)
+	if err != nil {
+		target.closeBackend()
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	entities, err := splitDoc(doc)
+	if err != nil {
+		target.closeBackend()
+		return err
+	}
+	if err := target.write(diffEntities(nil, entities)); err == nil {
+		err = target.writeHistory(h)
+	}
+	if err != nil {
+		target.closeBackend()
+		os.Remove(target.path())
+		return err
+	}
+	if err := target.closeBackend(); err != nil {
+		return err
+	}
+
+	// Move the old file out of the way so openBackend picks the new one
+	old := s.backend
+	if err := old.closeBackend(); err != nil {
+		return err
+	}
+	if fileExists(old.path()) {
+		if err := os.Rename(old.path(), old.path()+".migrated-"+time.Now().Format("20060102-150405")); err != nil {
+			return fmt.Errorf("failed to move old data file: %w", err)
+		}
+	}
+	// The history moved into the database along with the ledger
+	if kind == BackendSQLite {
+		os.Remove(s.historyPath())
+	}
+
+	err = s.lock.release()
+	s.lock = nil
+	return err
+}
+
+// entityKey identifies one record of the ledger. Members of top-level
+// objects (wallets, transactions, prices, ...) are keyed by their object
+// name; top-level scalars like schema_version use the "meta" kind.
+type entityKey struct {
+	kind string
+	key  string
+}
+
+// entityChange is one record that differs between two versions of the
+// ledger; a nil before or after means the record was added or removed
+type entityChange struct {
+	key    entityKey
+	before []byte
+	after  []byte
+}
+
+// splitDoc breaks a wago.json document into compact per-entity JSON
+func splitDoc(doc []byte) (map[entityKey][]byte, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &top); err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	entities := make(map[entityKey][]byte)
+	for kind, value := range top {
+		var members map[string]json.RawMessage
+		if bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) && json.Unmarshal(value, &members) == nil {
+			for key, member := range members {
+				compact, err := compactJSON(member)
+				if err != nil {
+					return nil, err
+				}
+				entities[entityKey{kind, key}] = compact
+			}
+			continue
+		}
+
+		compact, err := compactJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		entities[entityKey{metaKind, kind}] = compact
+	}
+	return entities, nil
+}
+
+// assembleDoc is the inverse of splitDoc
+func assembleDoc(entities map[entityKey][]byte) ([]byte, error) {
+	top := make(map[string]interface{})
+	for k, value := range entities {
+		if k.kind == metaKind {
+			top[k.key] = json.RawMessage(value)
+			continue
+		}
+		members, ok := top[k.kind].(map[string]json.RawMessage)
+		if !ok {
+			members = make(map[string]json.RawMessage)
+			top[k.kind] = members
+		}
+		members[k.key] = json.RawMessage(value)
+	}
+	return json.Marshal(top)
+}
+
+// diffEntities lists the records that differ between old and cur
+func diffEntities(old, cur map[entityKey][]byte) []entityChange {
+	var changes []entityChange
+	for k, after := range cur {
+		if before, exists := old[k]; !exists || !bytes.Equal(before, after) {
+			changes = append(changes, entityChange{key: k, before: old[k], after: after})
+		}
+	}
+	for k, before := range old {
+		if _, exists := cur[k]; !exists {
+			changes = append(changes, entityChange{key: k, before: before})
+		}
+	}
+
+	sortChanges(changes)
+	return changes
+}
+
+// sortChanges orders changes by kind and key
+func sortChanges(changes []entityChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].key.kind != changes[j].key.kind {
+			return changes[i].key.kind < changes[j].key.kind
+		}
+		return changes[i].key.key < changes[j].key.key
+	})
+}
+
+// compactJSON strips insignificant whitespace so equal values compare equal
+func compactJSON(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
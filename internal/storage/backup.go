@@ -0,0 +1,367 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/vasylcode/wago/internal/model"
+)
+
+const (
+	backupsDirName     = "backups"
+	backupPrefix       = "wago-"
+	backupSuffix       = ".json"
+	backupIDFormat     = "20060102-150405.000000"
+	defaultBackupCount = 10
+
+	incrementalBackupInterval = 5 * time.Minute
+)
+
+// Backup describes a snapshot of the data file taken before a save
+type Backup struct {
+	ID   string
+	Time time.Time
+	Size int64
+}
+
+// BalanceChange is a wallet balance that differs between two snapshots
+type BalanceChange struct {
+	Wallet string
+	Coin   string
+	Old    decimal.Decimal
+	New    decimal.Decimal
+}
+
+// PriceChange is a coin price that differs between two snapshots
+type PriceChange struct {
+	Coin string
+	Old  float64 // 0 when the price was added
+	New  float64 // 0 when the price was removed
+}
+
+// DataDiff summarizes what changed between two snapshots of the ledger
+type DataDiff struct {
+	WalletsAdded   []string
+	WalletsRemoved []string
+	BalanceChanges []BalanceChange
+	TxsAdded       []*model.Tx
+	TxsRemoved     []*model.Tx
+	PriceChanges   []PriceChange
+}
+
+// Empty reports whether the snapshots are identical in the compared fields
+func (d *DataDiff) Empty() bool {
+	return len(d.WalletsAdded) == 0 && len(d.WalletsRemoved) == 0 &&
+		len(d.BalanceChanges) == 0 && len(d.TxsAdded) == 0 &&
+		len(d.TxsRemoved) == 0 && len(d.PriceChanges) == 0
+}
+
+// backupCount returns how many snapshots to keep, from WAGO_BACKUPS
+func backupCount() int {
+	if env := os.Getenv("WAGO_BACKUPS"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultBackupCount
+}
+
+// backupsDir returns the directory holding this profile's snapshots
+func (s *Storage) backupsDir() string {
+	return filepath.Join(s.dataDir, backupsDirName)
+}
+
+// writeBackup snapshots the data file as it is on disk and prunes old
+// snapshots beyond the configured count. While the ledger is encrypted,
+// snapshots are too, even of a file that is still plain on disk.
+func (s *Storage) writeBackup() error {
+	keep := backupCount()
+	if keep == 0 {
+		return nil
+	}
+
+	// Snapshotting a database means exporting all of it, so incremental
+	// backends take at most one snapshot per interval
+	if s.backend.incremental() {
+		backups, err := s.ListBackups()
+		if err != nil {
+			return err
+		}
+		if len(backups) > 0 && time.Since(backups[0].Time) < incrementalBackupInterval {
+			return nil
+		}
+	}
+
+	raw, err := s.backend.read()
+	if err != nil {
+		return fmt.Errorf("failed to read data for backup: %w", err)
+	}
+	if raw == nil {
+		return nil
+	}
+	if s.key != nil && !isEncrypted(raw) {
+		if raw, err = s.key.seal(raw); err != nil {
+			return fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+	}
+
+	dir := s.backupsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backups directory: %w", err)
+	}
+
+	id := time.Now().Format(backupIDFormat)
+	if err := writeFileAtomic(filepath.Join(dir, backupPrefix+id+backupSuffix), raw, secretFileMode); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		os.Remove(s.backupPath(backups[i].ID))
+	}
+	return nil
+}
+
+// ledgerCopies lists every copy of the ledger kept next to it: snapshots,
+// pre-migration backups, sync ancestors and data files left behind by
+// migrate-backend
+func (s *Storage) ledgerCopies() ([]string, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, b := range backups {
+		paths = append(paths, s.backupPath(b.ID))
+	}
+
+	for _, pattern := range []string{
+		filepath.Join(s.dataDir, dataFileName+".v*.bak"),
+		filepath.Join(s.dataDir, dataFileName+".migrated-*"),
+		filepath.Join(s.dataDir, sqliteFileName+".migrated-*"),
+		filepath.Join(s.dataDir, syncDirName, "*.json"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// resealCopies rewrites every copy of the ledger after it was encrypted or
+// decrypted, so no plain copy is left next to an encrypted ledger. Copies
+// that aren't JSON, like a database left by migrate-backend, are sealed
+// as they are and come back unchanged on decrypt.
+func (s *Storage) resealCopies() error {
+	paths, err := s.ledgerCopies()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if isEncrypted(raw) == (s.key != nil) {
+			continue
+		}
+
+		if s.key != nil {
+			raw, err = s.key.seal(raw)
+		} else {
+			raw, _, err = openEnvelope(raw, options.KeyFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to reseal %s: %w", path, err)
+		}
+		if err := writeFileAtomic(path, raw, secretFileMode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// backupPath returns the file holding the snapshot with the given ID
+func (s *Storage) backupPath(id string) string {
+	return filepath.Join(s.backupsDir(), backupPrefix+id+backupSuffix)
+}
+
+// ListBackups returns all snapshots, newest first
+func (s *Storage) ListBackups() ([]Backup, error) {
+	entries, err := os.ReadDir(s.backupsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backups directory: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+		t, err := time.ParseInLocation(backupIDFormat, id, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{ID: id, Time: t, Size: info.Size()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// LoadBackup reads a snapshot, decrypting and migrating it as needed
+func (s *Storage) LoadBackup(id string) (*model.Data, error) {
+	raw, err := os.ReadFile(s.backupPath(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("backup '%s' not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if isEncrypted(raw) {
+		if raw, _, err = openEnvelope(raw, options.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	data, _, err := decodeData(raw)
+	return data, err
+}
+
+// RestoreBackup replaces the ledger with a snapshot. The current file is
+// itself backed up first, so a restore can be undone.
+func (s *Storage) RestoreBackup(id string) error {
+	data, err := s.LoadBackup(id)
+	if err != nil {
+		return err
+	}
+
+	s.data = data
+	s.buildTxIndex()
+	s.touchAll()
+	return s.save()
+}
+
+// DiffBackup compares a snapshot with the current ledger
+func (s *Storage) DiffBackup(id string) (*DataDiff, error) {
+	old, err := s.LoadBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	return DiffData(old, s.data), nil
+}
+
+// DiffData compares wallets, balances, transactions and prices of two snapshots
+func DiffData(old, cur *model.Data) *DataDiff {
+	diff := &DataDiff{}
+
+	for name, wallet := range cur.Wallets {
+		oldWallet, exists := old.Wallets[name]
+		if !exists {
+			diff.WalletsAdded = append(diff.WalletsAdded, name)
+			oldWallet = &model.Wallet{}
+		}
+		diff.BalanceChanges = append(diff.BalanceChanges, diffBalances(name, oldWallet, wallet)...)
+	}
+	for name, wallet := range old.Wallets {
+		if _, exists := cur.Wallets[name]; !exists {
+			diff.WalletsRemoved = append(diff.WalletsRemoved, name)
+			diff.BalanceChanges = append(diff.BalanceChanges, diffBalances(name, wallet, &model.Wallet{})...)
+		}
+	}
+
+	for id, tx := range cur.Transactions {
+		if _, exists := old.Transactions[id]; !exists {
+			diff.TxsAdded = append(diff.TxsAdded, tx)
+		}
+	}
+	for id, tx := range old.Transactions {
+		if _, exists := cur.Transactions[id]; !exists {
+			diff.TxsRemoved = append(diff.TxsRemoved, tx)
+		}
+	}
+
+	for coin, price := range cur.Prices {
+		if oldPrice, exists := old.Prices[coin]; !exists || oldPrice != price {
+			diff.PriceChanges = append(diff.PriceChanges, PriceChange{Coin: coin, Old: oldPrice, New: price})
+		}
+	}
+	for coin, price := range old.Prices {
+		if _, exists := cur.Prices[coin]; !exists {
+			diff.PriceChanges = append(diff.PriceChanges, PriceChange{Coin: coin, Old: price})
+		}
+	}
+
+	sort.Strings(diff.WalletsAdded)
+	sort.Strings(diff.WalletsRemoved)
+	sort.Slice(diff.BalanceChanges, func(i, j int) bool {
+		if diff.BalanceChanges[i].Wallet != diff.BalanceChanges[j].Wallet {
+			return diff.BalanceChanges[i].Wallet < diff.BalanceChanges[j].Wallet
+		}
+		return diff.BalanceChanges[i].Coin < diff.BalanceChanges[j].Coin
+	})
+	sortTxsByDate(diff.TxsAdded)
+	sortTxsByDate(diff.TxsRemoved)
+	sort.Slice(diff.PriceChanges, func(i, j int) bool {
+		return diff.PriceChanges[i].Coin < diff.PriceChanges[j].Coin
+	})
+
+	return diff
+}
+
+// diffBalances lists the coins whose balance differs between two versions of a wallet
+func diffBalances(name string, old, cur *model.Wallet) []BalanceChange {
+	amounts := make(map[string]*BalanceChange)
+	for _, bal := range old.Balances {
+		amounts[bal.Label()] = &BalanceChange{Wallet: name, Coin: bal.Label(), Old: bal.Amount}
+	}
+	for _, bal := range cur.Balances {
+		if change, exists := amounts[bal.Label()]; exists {
+			change.New = bal.Amount
+		} else {
+			amounts[bal.Label()] = &BalanceChange{Wallet: name, Coin: bal.Label(), New: bal.Amount}
+		}
+	}
+
+	var changes []BalanceChange
+	for _, change := range amounts {
+		if !change.Old.Equal(change.New) {
+			changes = append(changes, *change)
+		}
+	}
+	return changes
+}
+
+// sortTxsByDate orders transactions oldest first
+func sortTxsByDate(txs []*model.Tx) {
+	sort.Slice(txs, func(i, j int) bool {
+		if !txs[i].Date.Equal(txs[j].Date) {
+			return txs[i].Date.Before(txs[j].Date)
+		}
+		return txs[i].ID < txs[j].ID
+	})
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errBatchInProgress is returned by operations that must not run inside a batch
+var errBatchInProgress = errors.New("not allowed while a batch is in progress")
+
+// Begin starts a batch: changes made until Commit stay in memory and are
+// then saved at once, as a single backup and undo step. Batches don't nest.
+func (s *Storage) Begin() error {
+	if s.batch {
+		return errBatchInProgress
+	}
+	s.batch = true
+	return nil
+}
+
+// Commit replays the balances touched since Begin and saves all changes.
+// If a transaction overdraws a staked balance or LP position, or saving
+// fails, the changes are rolled back.
+func (s *Storage) Commit() error {
+	if !s.batch {
+		return fmt.Errorf("no batch in progress")
+	}
+	s.batch = false
+
+	txs := s.unsettled
+	s.unsettled = nil
+	err := s.settle(txs...)
+	if err == nil {
+		err = s.save()
+	}
+	if err != nil {
+		if rbErr := s.restorePersisted(); rbErr != nil {
+			return fmt.Errorf("%v (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Rollback discards all changes made since Begin
+func (s *Storage) Rollback() error {
+	if !s.batch {
+		return fmt.Errorf("no batch in progress")
+	}
+	s.batch = false
+	return s.restorePersisted()
+}
+
+// Update runs fn in a batch, committing when it returns nil and rolling
+// back when it returns an error
+func (s *Storage) Update(fn func(tx Store) error) error {
+	if err := s.Begin(); err != nil {
+		return err
+	}
+	if err := fn(s.backend); err != nil {
+		if rbErr := s.Rollback(); rbErr != nil {
+			return fmt.Errorf("%v (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return s.Commit()
+}
+
+// restorePersisted resets the in-memory ledger to what was last saved
+func (s *Storage) restorePersisted() error {
+	s.dirty = make(map[entityKey]bool)
+	s.dirtyAll = false
+	s.unsettled = nil
+	if len(s.persisted) == 0 {
+		s.data = newData()
+		s.buildTxIndex()
+		return nil
+	}
+
+	doc, err := assembleDoc(s.persisted)
+	if err != nil {
+		return err
+	}
+	data, _, err := decodeData(doc)
+	if err != nil {
+		return err
+	}
+	s.data = data
+	s.buildTxIndex()
+	return nil
+}
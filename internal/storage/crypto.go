@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	encryptedFormat = "wago-encrypted-v1"
+
+	// scrypt parameters recommended for interactive logins
+	scryptN        = 1 << 15
+	scryptR        = 8
+	scryptP        = 1
+	keyLength      = 32
+	saltLength     = 16
+	promptTries    = 3
+	secretFileMode = 0600
+
+	// Bounds on the scrypt parameters of an envelope, well above the
+	// ones wago writes
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+// PassphrasePrompt asks the user for the ledger passphrase. It is used when
+// neither WAGO_PASSPHRASE nor a keyfile is available; nil disables prompting.
+var PassphrasePrompt func(prompt string) (string, error)
+
+// envelope is the on-disk form of an encrypted ledger
+type envelope struct {
+	Format     string `json:"format"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ledgerKey is a key derived from the passphrase, kept with its salt so
+// every save can re-encrypt without running the KDF again
+type ledgerKey struct {
+	salt []byte
+	key  []byte
+}
+
+var (
+	// unlockedPassphrase caches the last passphrase that opened a ledger, so
+	// the dashboard and nested storage.New calls don't prompt repeatedly
+	unlockedPassphrase string
+	derivedKeys        = make(map[[sha256.Size]byte][]byte)
+)
+
+// ErrWrongPassphrase is returned when a ledger can't be decrypted
+var ErrWrongPassphrase = errors.New("incorrect passphrase")
+
+// isEncrypted reports whether raw is an encrypted ledger envelope
+func isEncrypted(raw []byte) bool {
+	var header struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(raw, &header) == nil && header.Format == encryptedFormat
+}
+
+// newLedgerKey derives a key for passphrase with a fresh random salt
+func newLedgerKey(passphrase string) (*ledgerKey, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := deriveKey(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	return &ledgerKey{salt: salt, key: key}, nil
+}
+
+// deriveKey runs scrypt, caching results for the lifetime of the process
+func deriveKey(passphrase string, salt []byte, n, r, p int) ([]byte, error) {
+	cacheKey := sha256.Sum256([]byte(fmt.Sprintf("%x|%d|%d|%d|%s", salt, n, r, p, passphrase)))
+	if key, ok := derivedKeys[cacheKey]; ok {
+		return key, nil
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	derivedKeys[cacheKey] = key
+	return key, nil
+}
+
+// seal encrypts plain into an envelope using a fresh nonce
+func (k *ledgerKey) seal(plain []byte) ([]byte, error) {
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	env := envelope{
+		Format:     encryptedFormat,
+		KDF:        "scrypt",
+		N:          scryptN,
+		R:          scryptR,
+		P:          scryptP,
+		Salt:       k.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plain, []byte(encryptedFormat)),
+	}
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// openEnvelope decrypts raw, asking for the passphrase if necessary. It
+// returns the plaintext and the key to re-encrypt with on save.
+func openEnvelope(raw []byte, keyFile string) ([]byte, *ledgerKey, error) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse encrypted data file: %w", err)
+	}
+	if env.KDF != "scrypt" {
+		return nil, nil, fmt.Errorf("unsupported key derivation '%s'", env.KDF)
+	}
+	if err := checkEnvelope(&env); err != nil {
+		return nil, nil, err
+	}
+
+	tryPassphrase := func(passphrase string) ([]byte, *ledgerKey, error) {
+		key, err := deriveKey(passphrase, env.Salt, env.N, env.R, env.P)
+		if err != nil {
+			return nil, nil, err
+		}
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		plain, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(encryptedFormat))
+		if err != nil {
+			return nil, nil, ErrWrongPassphrase
+		}
+		unlockedPassphrase = passphrase
+		return plain, &ledgerKey{salt: env.Salt, key: key}, nil
+	}
+
+	// A cached passphrase from this process is tried silently
+	if unlockedPassphrase != "" {
+		if plain, key, err := tryPassphrase(unlockedPassphrase); err == nil {
+			return plain, key, nil
+		}
+	}
+
+	// Non-interactive sources must be right the first time
+	passphrase, err := configuredPassphrase(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if passphrase != "" {
+		return tryPassphrase(passphrase)
+	}
+
+	if PassphrasePrompt == nil {
+		return nil, nil, fmt.Errorf("data file is encrypted: set WAGO_PASSPHRASE or WAGO_KEYFILE")
+	}
+	for i := 0; i < promptTries; i++ {
+		passphrase, err := PassphrasePrompt("Passphrase: ")
+		if err != nil {
+			return nil, nil, err
+		}
+		plain, key, err := tryPassphrase(passphrase)
+		if !errors.Is(err, ErrWrongPassphrase) {
+			return plain, key, err
+		}
+	}
+	return nil, nil, ErrWrongPassphrase
+}
+
+// errCorruptEnvelope is returned for an envelope that can't have been
+// written by wago
+var errCorruptEnvelope = errors.New("corrupt encrypted file")
+
+// checkEnvelope rejects envelopes with KDF parameters outside what wago
+// writes or can afford to run, or a nonce AES-GCM would choke on
+func checkEnvelope(env *envelope) error {
+	if env.N < 2 || env.N > maxScryptN || env.N&(env.N-1) != 0 {
+		return fmt.Errorf("%w: scrypt N=%d", errCorruptEnvelope, env.N)
+	}
+	if env.R < 1 || env.R > maxScryptR || env.P < 1 || env.P > maxScryptP {
+		return fmt.Errorf("%w: scrypt r=%d p=%d", errCorruptEnvelope, env.R, env.P)
+	}
+	if len(env.Salt) == 0 {
+		return fmt.Errorf("%w: missing salt", errCorruptEnvelope)
+	}
+
+	// The nonce size doesn't depend on the key
+	gcm, err := newGCM(make([]byte, keyLength))
+	if err != nil {
+		return err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return fmt.Errorf("%w: nonce is %d bytes", errCorruptEnvelope, len(env.Nonce))
+	}
+	return nil
+}
+
+// ConfiguredPassphrase returns the passphrase from WAGO_PASSPHRASE or the
+// configured keyfile, or "" when neither is set
+func ConfiguredPassphrase() (string, error) {
+	return configuredPassphrase(options.KeyFile)
+}
+
+// configuredPassphrase returns the passphrase from WAGO_PASSPHRASE or the
+// keyfile, or "" when neither is set
+func configuredPassphrase(keyFile string) (string, error) {
+	if passphrase := os.Getenv("WAGO_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if keyFile == "" {
+		keyFile = os.Getenv("WAGO_KEYFILE")
+	}
+	if keyFile == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keyfile: %w", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("keyfile %s is empty", keyFile)
+	}
+	return passphrase, nil
+}
+
+// newGCM builds an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
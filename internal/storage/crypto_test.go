@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withPassphrase makes passphrase the only one openEnvelope can find
+func withPassphrase(t *testing.T, passphrase string) {
+	t.Helper()
+	t.Setenv("WAGO_PASSPHRASE", passphrase)
+	t.Setenv("WAGO_KEYFILE", "")
+	prompt, cached := PassphrasePrompt, unlockedPassphrase
+	PassphrasePrompt, unlockedPassphrase = nil, ""
+	t.Cleanup(func() { PassphrasePrompt, unlockedPassphrase = prompt, cached })
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	key, err := newLedgerKey("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte(`{"schema_version":3}`)
+	sealed, err := key.seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(sealed) {
+		t.Fatal("isEncrypted() = false for a sealed ledger")
+	}
+	if isEncrypted(plain) {
+		t.Fatal("isEncrypted() = true for a plain ledger")
+	}
+	if strings.Contains(string(sealed), "schema_version") {
+		t.Fatal("sealed envelope contains the plaintext")
+	}
+
+	// Every seal uses a fresh nonce
+	again, err := key.seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) == string(sealed) {
+		t.Error("two seals of the same plaintext are identical")
+	}
+
+	withPassphrase(t, "correct horse")
+	opened, openedKey, err := openEnvelope(sealed, "")
+	if err != nil {
+		t.Fatalf("openEnvelope() error = %v", err)
+	}
+	if string(opened) != string(plain) {
+		t.Errorf("openEnvelope() = %s, want %s", opened, plain)
+	}
+	if string(openedKey.key) != string(key.key) || string(openedKey.salt) != string(key.salt) {
+		t.Error("openEnvelope() returned a different key")
+	}
+}
+
+func TestOpenEnvelopeKeyFile(t *testing.T) {
+	key, err := newLedgerKey("from a file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.seal([]byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withPassphrase(t, "")
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("from a file\n"), secretFileMode); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openEnvelope(sealed, keyFile); err != nil {
+		t.Errorf("openEnvelope() error = %v", err)
+	}
+}
+
+func TestOpenEnvelopeErrors(t *testing.T) {
+	key, err := newLedgerKey("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.seal([]byte(`{"schema_version":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tamper decodes the sealed envelope, changes it and encodes it again
+	tamper := func(change func(env *envelope)) []byte {
+		var env envelope
+		if err := json.Unmarshal(sealed, &env); err != nil {
+			t.Fatal(err)
+		}
+		change(&env)
+		raw, err := json.Marshal(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	tests := []struct {
+		name       string
+		raw        []byte
+		passphrase string
+		wantErr    error
+		wantMsg    string
+	}{
+		{
+			name:       "wrong passphrase",
+			raw:        sealed,
+			passphrase: "battery staple",
+			wantErr:    ErrWrongPassphrase,
+		},
+		{
+			name:       "no passphrase and no prompt",
+			raw:        sealed,
+			passphrase: "",
+			wantMsg:    "set WAGO_PASSPHRASE or WAGO_KEYFILE",
+		},
+		{
+			name:       "tampered ciphertext",
+			raw:        tamper(func(env *envelope) { env.Ciphertext[0] ^= 0xff }),
+			passphrase: "correct horse",
+			wantErr:    ErrWrongPassphrase,
+		},
+		{
+			name:       "not json",
+			raw:        []byte(`{"format":"wago-encrypted-v1",`),
+			passphrase: "correct horse",
+			wantMsg:    "failed to parse encrypted data file",
+		},
+		{
+			name:       "unknown kdf",
+			raw:        tamper(func(env *envelope) { env.KDF = "pbkdf2" }),
+			passphrase: "correct horse",
+			wantMsg:    "unsupported key derivation 'pbkdf2'",
+		},
+		{
+			name:       "short nonce",
+			raw:        tamper(func(env *envelope) { env.Nonce = env.Nonce[:3] }),
+			passphrase: "correct horse",
+			wantErr:    errCorruptEnvelope,
+		},
+		{
+			name:       "missing salt",
+			raw:        tamper(func(env *envelope) { env.Salt = nil }),
+			passphrase: "correct horse",
+			wantErr:    errCorruptEnvelope,
+		},
+		{
+			name:       "N not a power of two",
+			raw:        tamper(func(env *envelope) { env.N = 3 << 10 }),
+			passphrase: "correct horse",
+			wantErr:    errCorruptEnvelope,
+		},
+		{
+			name:       "N too large",
+			raw:        tamper(func(env *envelope) { env.N = 1 << 30 }),
+			passphrase: "correct horse",
+			wantErr:    errCorruptEnvelope,
+		},
+		{
+			name:       "r zero",
+			raw:        tamper(func(env *envelope) { env.R = 0 }),
+			passphrase: "correct horse",
+			wantErr:    errCorruptEnvelope,
+		},
+		{
+			name:       "p too large",
+			raw:        tamper(func(env *envelope) { env.P = 1 << 20 }),
+			passphrase: "correct horse",
+			wantErr:    errCorruptEnvelope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPassphrase(t, tt.passphrase)
+			_, _, err := openEnvelope(tt.raw, "")
+			if err == nil {
+				t.Fatal("openEnvelope() succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("openEnvelope() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("openEnvelope() error = %v, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
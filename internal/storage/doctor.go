@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// Issue kinds reported by Doctor
+const (
+	IssueMissingWallet    = "missing-wallet"
+	IssueBalanceMismatch  = "balance-mismatch"
+	IssueMissingCategory  = "missing-category"
+	IssueDuplicateAddress = "duplicate-address"
+	IssueCoinCase         = "coin-case"
+	IssueNegativeBalance  = "negative-balance"
+	IssueZeroPrice        = "zero-price"
+)
+
+// recreatedCategoryColor is used for categories restored by Doctor
+const recreatedCategoryColor = "white"
+
+// Issue is a problem found in the ledger
+type Issue struct {
+	Kind    string
+	Message string
+	Fixable bool
+}
+
+// Doctor checks the ledger for inconsistencies. With fix, fixable issues
+// are repaired and saved in one change, so they can be undone together:
+//   - transactions referencing missing wallets are deleted, or for
+//     transfers the missing side and for multi-leg transactions the
+//     missing legs are dropped
+//   - coin symbols are uppercased
+//   - missing categories are recreated
+//   - zero prices are removed
+//   - cached balances are replayed from the transactions
+//
+// Duplicate addresses and negative balances need a human and are only
+// reported.
+func (s *Storage) Doctor(fix bool) ([]Issue, error) {
+	var issues []Issue
+	report := func(kind string, fixable bool, format string, args ...interface{}) {
+		issues = append(issues, Issue{Kind: kind, Message: fmt.Sprintf(format, args...), Fixable: fixable})
+	}
+
+	// Transactions referencing missing wallets
+	for _, tx := range s.journal() {
+		for _, name := range txWallets(tx) {
+			if !s.isCounterparty(tx, name) {
+				report(IssueMissingWallet, true, "transaction %s references missing wallet '%s'", tx.ID, name)
+			}
+		}
+	}
+	if fix {
+		s.fixMissingWallets()
+	}
+
+	// Coin symbols differing only by case
+	spellings := coinSpellings(s.data)
+	symbols := make([]string, 0, len(spellings))
+	for symbol := range spellings {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		if len(spellings[symbol]) > 1 {
+			report(IssueCoinCase, true, "coin %s is spelled as %s", symbol, strings.Join(spellings[symbol], ", "))
+		}
+	}
+	if fix {
+		s.fixCoinCase()
+	}
+
+	// Wallets pointing at deleted categories
+	for _, wallet := range s.sortedWallets() {
+		if wallet.Category == "" {
+			continue
+		}
+		if _, exists := s.data.Categories[wallet.Category]; !exists {
+			report(IssueMissingCategory, true, "wallet '%s' uses missing category '%s'", wallet.Name, wallet.Category)
+			if fix {
+				s.data.Categories[wallet.Category] = &model.Category{Name: wallet.Category, Color: recreatedCategoryColor}
+			}
+		}
+	}
+
+	// Duplicate addresses
+	owners := make(map[string][]string)
+	for _, wallet := range s.sortedWallets() {
+		if wallet.Address != "" {
+			addr := strings.ToLower(wallet.Address)
+			owners[addr] = append(owners[addr], wallet.Name)
+		}
+	}
+	addrs := make([]string, 0, len(owners))
+	for addr := range owners {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		if len(owners[addr]) > 1 {
+			report(IssueDuplicateAddress, false, "address %s is used by wallets %s", addr, strings.Join(owners[addr], ", "))
+		}
+	}
+
+	// Zero prices
+	coins := make([]string, 0, len(s.data.Prices))
+	for coin := range s.data.Prices {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	for _, coin := range coins {
+		if s.data.Prices[coin] == 0 {
+			report(IssueZeroPrice, true, "price of %s is zero", strings.ToUpper(coin))
+			if fix {
+				delete(s.data.Prices, coin)
+				delete(s.data.PriceUpdated, coin)
+			}
+		}
+	}
+
+	// Cached balances vs. the journal
+	saved := make(map[string][]*model.Balance, len(s.data.Wallets))
+	for name, wallet := range s.data.Wallets {
+		saved[name] = wallet.Balances
+	}
+	for _, change := range s.refreshBalances(s.walletNames()...) {
+		report(IssueBalanceMismatch, true, "wallet '%s' holds %s %s but its transactions add up to %s",
+			change.Wallet, change.Old, change.Coin, change.New)
+	}
+	if !fix {
+		for name, balances := range saved {
+			s.data.Wallets[name].Balances = balances
+		}
+	}
+
+	// Negative balances, after replay when fixing
+	for _, wallet := range s.sortedWallets() {
+		for _, bal := range wallet.Balances {
+			if bal.Amount.IsNegative() {
+				report(IssueNegativeBalance, false, "wallet '%s' has a negative %s balance of %s", wallet.Name, bal.Label(), bal.Amount)
+			}
+		}
+	}
+
+	if fix {
+		s.buildTxIndex()
+		s.touchAll()
+		if err := s.save(); err != nil {
+			return issues, err
+		}
+	}
+	return issues, nil
+}
+
+// fixMissingWallets deletes transactions whose wallets are all gone and
+// detaches transfers from a missing side
+func (s *Storage) fixMissingWallets() {
+	for id, tx := range s.data.Transactions {
+		exists := func(name string) bool {
+			return name != "" && s.isCounterparty(tx, name)
+		}
+
+		// A missing fee wallet hands the fee back to the sender
+		if tx.FeeWallet != "" && !exists(tx.FeeWallet) {
+			tx.FeeWallet = ""
+		}
+
+		// Legs of missing parties are dropped, the rest stays
+		if tx.Type == model.TxTypeMulti {
+			legs := tx.Legs[:0]
+			for _, leg := range tx.Legs {
+				if exists(leg.Wallet) {
+					legs = append(legs, leg)
+				}
+			}
+			tx.Legs = legs
+			if len(legs) == 0 {
+				delete(s.data.Transactions, id)
+			}
+			continue
+		}
+
+		if tx.Type == model.TxTypeTransfer && (exists(tx.FromWallet) || exists(tx.ToWallet)) {
+			if !exists(tx.FromWallet) {
+				tx.FromWallet = ""
+			}
+			if !exists(tx.ToWallet) {
+				tx.ToWallet = ""
+			}
+			continue
+		}
+
+		for _, name := range txWallets(tx) {
+			if !exists(name) {
+				delete(s.data.Transactions, id)
+				break
+			}
+		}
+	}
+}
+
+// isCounterparty reports whether name is a valid party of tx: a wallet,
+// or for transfers also a contact from the address book
+func (s *Storage) isCounterparty(tx *model.Tx, name string) bool {
+	if _, exists := s.data.Wallets[name]; exists {
+		return true
+	}
+	if tx.Type == model.TxTypeTransfer || tx.Type == model.TxTypeMulti {
+		_, exists := s.data.Contacts[name]
+		return exists
+	}
+	return false
+}
+
+// fixCoinCase uppercases coin symbols in transactions; balances follow
+// when they are replayed
+func (s *Storage) fixCoinCase() {
+	for _, tx := range s.data.Transactions {
+		tx.Coin = strings.ToUpper(tx.Coin)
+		tx.SellCoin = strings.ToUpper(tx.SellCoin)
+		tx.BuyCoin = strings.ToUpper(tx.BuyCoin)
+		tx.ReceivedCoin = strings.ToUpper(tx.ReceivedCoin)
+		tx.PairCoin = strings.ToUpper(tx.PairCoin)
+		for i := range tx.Legs {
+			tx.Legs[i].Coin = strings.ToUpper(tx.Legs[i].Coin)
+		}
+	}
+	for _, wallet := range s.data.Wallets {
+		for _, bal := range wallet.Balances {
+			bal.Coin = strings.ToUpper(bal.Coin)
+		}
+	}
+}
+
+// coinSpellings groups the coin symbols used in transactions and balances
+// by their uppercase form
+func coinSpellings(data *model.Data) map[string][]string {
+	seen := make(map[string]map[string]bool)
+	add := func(coin string) {
+		if coin == "" {
+			return
+		}
+		upper := strings.ToUpper(coin)
+		if seen[upper] == nil {
+			seen[upper] = make(map[string]bool)
+		}
+		seen[upper][coin] = true
+	}
+	for _, tx := range data.Transactions {
+		add(tx.Coin)
+		add(tx.SellCoin)
+		add(tx.BuyCoin)
+		add(tx.ReceivedCoin)
+		add(tx.PairCoin)
+		for _, leg := range tx.Legs {
+			add(leg.Coin)
+		}
+	}
+	for _, wallet := range data.Wallets {
+		for _, bal := range wallet.Balances {
+			add(bal.Coin)
+		}
+	}
+
+	spellings := make(map[string][]string, len(seen))
+	for upper, variants := range seen {
+		for coin := range variants {
+			spellings[upper] = append(spellings[upper], coin)
+		}
+		sort.Strings(spellings[upper])
+	}
+	return spellings
+}
+
+// sortedWallets returns all wallets ordered by name
+func (s *Storage) sortedWallets() []*model.Wallet {
+	wallets := s.ListWallets()
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].Name < wallets[j].Name
+	})
+	return wallets
+}
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	historyFileName = "history.json"
+	maxHistory      = 100
+)
+
+// historyChange is one entity as it was before and after a mutation; a
+// missing side means the entity did not exist
+type historyChange struct {
+	Kind   string          `json:"kind"`
+	Key    string          `json:"key"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// historyEntry is one saved mutation of the ledger
+type historyEntry struct {
+	Time    time.Time       `json:"time"`
+	Summary string          `json:"summary"`
+	Changes []historyChange `json:"changes"`
+}
+
+// history holds the undo and redo stacks, newest last. It is tied to the
+// schema version its entities were written with.
+type history struct {
+	SchemaVersion int            `json:"schema_version"`
+	Undo          []historyEntry `json:"undo"`
+	Redo          []historyEntry `json:"redo"`
+}
+
+// historyPath returns the file holding this profile's undo history
+func (s *Storage) historyPath() string {
+	return filepath.Join(s.dataDir, historyFileName)
+}
+
+// readHistoryFile reads an undo history file, discarding history written
+// for another schema version since its entities can't be applied anymore
+func readHistoryFile(path string) (*history, error) {
+	h := &history{SchemaVersion: CurrentSchemaVersion}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	if isEncrypted(raw) {
+		if raw, _, err = openEnvelope(raw, options.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var stored history
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse history: %w", err)
+	}
+	if stored.SchemaVersion != CurrentSchemaVersion {
+		return h, nil
+	}
+	return &stored, nil
+}
+
+// trimHistory drops the oldest undo entries beyond maxHistory
+func trimHistory(h *history) {
+	if len(h.Undo) > maxHistory {
+		h.Undo = h.Undo[len(h.Undo)-maxHistory:]
+	}
+}
+
+// loadHistory reads the undo history from history.json
+func (b *JSONStore) loadHistory() (*history, error) {
+	return readHistoryFile(b.historyPath())
+}
+
+// writeHistory persists the undo history, encrypted like the ledger
+func (b *JSONStore) writeHistory(h *history) error {
+	trimHistory(h)
+
+	doc, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	data, perm, err := b.renderDoc(doc)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(b.historyPath(), data, perm); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// pushHistory adds entry to the undo stack. A new mutation makes the redo
+// stack meaningless, so it is dropped.
+func (b *JSONStore) pushHistory(entry historyEntry) error {
+	h, err := b.loadHistory()
+	if err != nil {
+		return err
+	}
+	h.Undo = append(h.Undo, entry)
+	h.Redo = nil
+	return b.writeHistory(h)
+}
+
+// resealHistory rewrites the undo history after the ledger was encrypted
+// or decrypted, so it is stored the same way
+func (b *JSONStore) resealHistory() error {
+	if !fileExists(b.historyPath()) {
+		return nil
+	}
+	h, err := b.loadHistory()
+	if err != nil {
+		return err
+	}
+	return b.writeHistory(h)
+}
+
+// recordHistory pushes a saved mutation onto the undo stack
+func (s *Storage) recordHistory(changes []entityChange) error {
+	entry := historyEntry{Time: time.Now(), Summary: summarizeChanges(changes)}
+	for _, change := range changes {
+		entry.Changes = append(entry.Changes, historyChange{
+			Kind:   change.key.kind,
+			Key:    change.key.key,
+			Before: change.before,
+			After:  change.after,
+		})
+	}
+	return s.backend.pushHistory(entry)
+}
+
+// Undo reverts the most recent mutation and returns its summary
+func (s *Storage) Undo() (string, error) {
+	if s.batch {
+		return "", errBatchInProgress
+	}
+
+	h, err := s.backend.loadHistory()
+	if err != nil {
+		return "", err
+	}
+	if len(h.Undo) == 0 {
+		return "", fmt.Errorf("nothing to undo")
+	}
+
+	entry := h.Undo[len(h.Undo)-1]
+	if err := s.applyHistory(entry, true); err != nil {
+		return "", fmt.Errorf("cannot undo '%s': %w", entry.Summary, err)
+	}
+
+	h.Undo = h.Undo[:len(h.Undo)-1]
+	h.Redo = append(h.Redo, entry)
+	return entry.Summary, s.backend.writeHistory(h)
+}
+
+// Redo reapplies the most recently undone mutation and returns its summary
+func (s *Storage) Redo() (string, error) {
+	if s.batch {
+		return "", errBatchInProgress
+	}
+
+	h, err := s.backend.loadHistory()
+	if err != nil {
+		return "", err
+	}
+	if len(h.Redo) == 0 {
+		return "", fmt.Errorf("nothing to redo")
+	}
+
+	entry := h.Redo[len(h.Redo)-1]
+	if err := s.applyHistory(entry, false); err != nil {
+		return "", fmt.Errorf("cannot redo '%s': %w", entry.Summary, err)
+	}
+
+	h.Redo = h.Redo[:len(h.Redo)-1]
+	h.Undo = append(h.Undo, entry)
+	return entry.Summary, s.backend.writeHistory(h)
+}
+
+// applyHistory moves the entities of entry back to their before state
+// (undo) or forward to their after state (redo). It refuses when any of
+// them changed in the meantime, e.g. by editing wago.json by hand.
+func (s *Storage) applyHistory(entry historyEntry, undo bool) error {
+	entities := make(map[entityKey][]byte, len(s.persisted))
+	for k, v := range s.persisted {
+		entities[k] = v
+	}
+
+	for _, change := range entry.Changes {
+		k := entityKey{change.Kind, change.Key}
+		expected, target := change.After, change.Before
+		if !undo {
+			expected, target = change.Before, change.After
+		}
+
+		// The history file is indented, entities are compared compact
+		var err error
+		if expected, err = compactRaw(expected); err != nil {
+			return err
+		}
+		if target, err = compactRaw(target); err != nil {
+			return err
+		}
+
+		if current, exists := entities[k]; exists != (expected != nil) || !bytes.Equal(current, expected) {
+			return fmt.Errorf("%s '%s' has changed since", singularKind(k.kind), k.key)
+		}
+		if target == nil {
+			delete(entities, k)
+		} else {
+			entities[k] = target
+		}
+	}
+
+	doc, err := assembleDoc(entities)
+	if err != nil {
+		return err
+	}
+	data, _, err := decodeData(doc)
+	if err != nil {
+		return err
+	}
+
+	s.data = data
+	s.buildTxIndex()
+	s.touchAll()
+	return s.persist(false)
+}
+
+// compactRaw compacts a history entity, keeping nil for a missing one
+func compactRaw(value json.RawMessage) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return compactJSON(value)
+}
+
+// summarizeChanges describes a mutation by its most significant change
+func summarizeChanges(changes []entityChange) string {
+	if len(changes) == 0 {
+		return "no changes"
+	}
+
+	rank := map[string]int{"transactions": 0, "wallets": 1, "categories": 2, "contacts": 3, "schedules": 4, "prices": 5}
+	primary := changes[0]
+	for _, change := range changes[1:] {
+		r, ok := rank[change.key.kind]
+		if p, pok := rank[primary.key.kind]; ok && (!pok || r < p) {
+			primary = change
+		}
+	}
+
+	verb := "update"
+	switch {
+	case primary.before == nil:
+		verb = "add"
+	case primary.after == nil:
+		verb = "delete"
+	}
+	summary := fmt.Sprintf("%s %s %s", verb, singularKind(primary.key.kind), primary.key.key)
+	if len(changes) > 1 {
+		summary += fmt.Sprintf(" (+%d more)", len(changes)-1)
+	}
+	return summary
+}
+
+// singularKind turns an entity kind like "categories" into "category"
+func singularKind(kind string) string {
+	switch {
+	case kind == metaKind:
+		return "setting"
+	case kind == "transactions":
+		return "tx"
+	case strings.HasSuffix(kind, "ies"):
+		return strings.TrimSuffix(kind, "ies") + "y"
+	case strings.HasSuffix(kind, "s"):
+		return strings.TrimSuffix(kind, "s")
+	}
+	return kind
+}
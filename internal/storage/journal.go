@@ -0,0 +1,397 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// balanceDelta is one wallet balance change caused by a transaction
+type balanceDelta struct {
+	wallet string
+	coin   string
+	amount decimal.Decimal
+	staked bool
+}
+
+// balanceKey identifies one balance entry of a wallet
+type balanceKey struct {
+	coin   string
+	staked bool
+}
+
+// txDeltas returns the balance changes a transaction applies. This is the
+// only place that defines what each transaction type does to balances.
+func txDeltas(tx *model.Tx) []balanceDelta {
+	var deltas []balanceDelta
+	switch tx.Type {
+	case model.TxTypeDeposit, model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+		deltas = []balanceDelta{{tx.ToWallet, tx.Coin, tx.Amount, false}}
+
+	case model.TxTypeWithdraw:
+		deltas = []balanceDelta{{tx.FromWallet, tx.Coin, tx.Amount.Neg(), false}}
+
+	case model.TxTypeTransfer:
+		if tx.FromWallet != "" {
+			deltas = append(deltas, balanceDelta{tx.FromWallet, tx.Coin, tx.Amount.Neg(), false})
+		}
+		if tx.ToWallet != "" {
+			deltas = append(deltas, balanceDelta{tx.ToWallet, tx.Coin, tx.Amount, false})
+		}
+
+	case model.TxTypeSwap:
+		deltas = []balanceDelta{
+			{tx.SwapWallet, tx.SellCoin, tx.SellAmount.Neg(), false},
+			{tx.SwapWallet, tx.BuyCoin, tx.BuyAmount, false},
+		}
+
+	case model.TxTypeBridge:
+		received, _ := BridgeReceived(tx)
+		deltas = []balanceDelta{
+			{tx.FromWallet, tx.Coin, tx.Amount.Neg(), false},
+			{tx.ToWallet, received, tx.ReceivedAmount, false},
+		}
+
+	case model.TxTypeLPAdd:
+		deltas = []balanceDelta{
+			{tx.FromWallet, tx.Coin, tx.Amount.Neg(), false},
+			{tx.FromWallet, tx.PairCoin, tx.PairAmount.Neg(), false},
+		}
+
+	case model.TxTypeLPRemove:
+		deltas = []balanceDelta{
+			{tx.FromWallet, tx.Coin, tx.Amount, false},
+			{tx.FromWallet, tx.PairCoin, tx.PairAmount, false},
+		}
+
+	case model.TxTypeMulti:
+		for _, leg := range tx.Legs {
+			deltas = append(deltas, balanceDelta{leg.Wallet, leg.Coin, leg.Amount, false})
+		}
+
+	case model.TxTypeStake:
+		deltas = []balanceDelta{
+			{tx.FromWallet, tx.Coin, tx.Amount.Neg(), false},
+			{tx.FromWallet, tx.Coin, tx.Amount, true},
+		}
+
+	case model.TxTypeUnstake:
+		deltas = []balanceDelta{
+			{tx.FromWallet, tx.Coin, tx.Amount.Neg(), true},
+			{tx.FromWallet, tx.Coin, tx.Amount, false},
+		}
+	}
+
+	if !tx.Fee.IsZero() {
+		if wallet, coin := FeePayer(tx); wallet != "" {
+			deltas = append(deltas, balanceDelta{wallet, coin, tx.Fee.Neg(), false})
+		}
+	}
+	return deltas
+}
+
+// FeePayer returns the wallet and coin a transaction's fee is paid from.
+// Unless set explicitly, the sending wallet pays in the coin it sends;
+// deposits have no sender, so the receiving wallet pays.
+func FeePayer(tx *model.Tx) (wallet, coin string) {
+	wallet, coin = tx.FeeWallet, tx.FeeCoin
+	if wallet == "" {
+		switch tx.Type {
+		case model.TxTypeDeposit, model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+			wallet = tx.ToWallet
+		case model.TxTypeWithdraw, model.TxTypeStake, model.TxTypeUnstake, model.TxTypeBridge,
+			model.TxTypeLPAdd, model.TxTypeLPRemove:
+			wallet = tx.FromWallet
+		case model.TxTypeTransfer:
+			wallet = tx.FromWallet
+			if wallet == "" {
+				wallet = tx.ToWallet
+			}
+		case model.TxTypeSwap:
+			wallet = tx.SwapWallet
+		case model.TxTypeMulti:
+			if leg := payingLeg(tx); leg != nil {
+				wallet = leg.Wallet
+			}
+		}
+	}
+	if coin == "" {
+		coin = tx.Coin
+		switch tx.Type {
+		case model.TxTypeSwap:
+			coin = tx.SellCoin
+		case model.TxTypeMulti:
+			if leg := payingLeg(tx); leg != nil {
+				coin = leg.Coin
+			}
+		}
+	}
+	return wallet, coin
+}
+
+// payingLeg returns the leg that pays a multi-leg transaction's fee by
+// default: the first one sending funds, or else the first one
+func payingLeg(tx *model.Tx) *model.Leg {
+	for i := range tx.Legs {
+		if tx.Legs[i].Amount.IsNegative() {
+			return &tx.Legs[i]
+		}
+	}
+	if len(tx.Legs) > 0 {
+		return &tx.Legs[0]
+	}
+	return nil
+}
+
+// BridgeReceived returns the coin and amount a bridge delivers on the
+// destination chain; the coin defaults to the one sent
+func BridgeReceived(tx *model.Tx) (coin string, amount decimal.Decimal) {
+	coin = tx.ReceivedCoin
+	if coin == "" {
+		coin = tx.Coin
+	}
+	return coin, tx.ReceivedAmount
+}
+
+// journal returns all transactions in replay order: by date, then by ID
+func (s *Storage) journal() []*model.Tx {
+	txs := s.ListTransactions()
+	sortTxsByDate(txs)
+	return txs
+}
+
+// replayBalances computes balances of the given wallets by replaying the
+// journal. Coins keep the order of the cached
+// balances, followed by new coins in the order they first appear.
+// Transactions touching wallets that no longer exist are skipped.
+func (s *Storage) replayBalances(names ...string) map[string][]*model.Balance {
+	scope := make(map[string]bool)
+	for _, name := range names {
+		if _, exists := s.data.Wallets[name]; exists {
+			scope[name] = true
+		}
+	}
+
+	amounts := make(map[string]map[balanceKey]decimal.Decimal)
+	order := make(map[string][]balanceKey)
+	addCoin := func(wallet string, key balanceKey) {
+		if _, seen := amounts[wallet][key]; !seen {
+			amounts[wallet][key] = decimal.Zero
+			order[wallet] = append(order[wallet], key)
+		}
+	}
+	for name := range scope {
+		amounts[name] = make(map[balanceKey]decimal.Decimal)
+		for _, bal := range s.data.Wallets[name].Balances {
+			addCoin(name, balanceKey{bal.Coin, bal.Staked})
+		}
+	}
+
+	// Cached coins without any transaction are dropped below
+	touched := make(map[string]map[balanceKey]bool)
+	for _, tx := range s.journal() {
+		for _, delta := range txDeltas(tx) {
+			if !scope[delta.wallet] {
+				continue
+			}
+			key := balanceKey{delta.coin, delta.staked}
+			addCoin(delta.wallet, key)
+			amounts[delta.wallet][key] = amounts[delta.wallet][key].Add(delta.amount)
+			if touched[delta.wallet] == nil {
+				touched[delta.wallet] = make(map[balanceKey]bool)
+			}
+			touched[delta.wallet][key] = true
+		}
+	}
+
+	balances := make(map[string][]*model.Balance, len(scope))
+	for name := range scope {
+		list := []*model.Balance{}
+		for _, key := range order[name] {
+			if touched[name][key] {
+				list = append(list, &model.Balance{Coin: key.coin, Amount: amounts[name][key], Staked: key.staked})
+			}
+		}
+		balances[name] = list
+	}
+	return balances
+}
+
+// BalanceAt replays the liquid balance of coin in wallet from the
+// transactions dated up to at
+func (s *Storage) BalanceAt(wallet, coin string, at time.Time) decimal.Decimal {
+	amount := decimal.Zero
+	for _, tx := range s.journal() {
+		if tx.Date.After(at) {
+			break
+		}
+		for _, delta := range txDeltas(tx) {
+			if delta.wallet == wallet && delta.coin == coin && !delta.staked {
+				amount = amount.Add(delta.amount)
+			}
+		}
+	}
+	return amount
+}
+
+// refreshBalances replaces the cached balances of the given wallets with
+// the replayed ones and returns what changed
+func (s *Storage) refreshBalances(names ...string) []BalanceChange {
+	var changes []BalanceChange
+	for name, balances := range s.replayBalances(names...) {
+		wallet := s.data.Wallets[name]
+		changes = append(changes, diffBalances(name, wallet, &model.Wallet{Balances: balances})...)
+		if len(balances) == 0 {
+			balances = nil
+		}
+		wallet.Balances = balances
+		s.touch(kindWallets, name)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Wallet != changes[j].Wallet {
+			return changes[i].Wallet < changes[j].Wallet
+		}
+		return changes[i].Coin < changes[j].Coin
+	})
+	return changes
+}
+
+// snapBalances replaces cached balances that differ from the journal only
+// by float rounding with the exact replayed amount. Larger differences,
+// e.g. balances set by hand, are kept for doctor and rebuild to report.
+func (s *Storage) snapBalances() {
+	tolerance := decimal.New(1, -9)
+	for name, balances := range s.replayBalances(s.walletNames()...) {
+		replayed := make(map[balanceKey]decimal.Decimal, len(balances))
+		for _, bal := range balances {
+			replayed[balanceKey{bal.Coin, bal.Staked}] = bal.Amount
+		}
+		for _, bal := range s.data.Wallets[name].Balances {
+			if amount, ok := replayed[balanceKey{bal.Coin, bal.Staked}]; ok && bal.Amount.Sub(amount).Abs().LessThanOrEqual(tolerance) {
+				bal.Amount = amount
+			}
+		}
+	}
+}
+
+// settle checks the holdings of the transactions just added, edited or
+// deleted and replays the balances of their wallets. Both replay the whole
+// journal, so inside a batch they wait for Commit and run once for all.
+func (s *Storage) settle(txs ...*model.Tx) error {
+	if s.batch {
+		s.unsettled = append(s.unsettled, txs...)
+		return nil
+	}
+	if err := s.checkHoldings(txs...); err != nil {
+		return err
+	}
+
+	var wallets []string
+	for _, tx := range txs {
+		wallets = append(wallets, txWallets(tx)...)
+	}
+	s.refreshBalances(wallets...)
+	return nil
+}
+
+// stakeKey identifies the staked balance of a coin in a wallet
+type stakeKey struct {
+	wallet string
+	coin   string
+}
+
+// checkHoldings replays the journal and returns an error if, at any point,
+// an unstake takes out more than is staked or an lp-remove burns more LP
+// tokens than the position holds. Only the staked balances and positions
+// the given transactions touch are checked, so an add, edit or delete can't
+// leave them negative.
+func (s *Storage) checkHoldings(txs ...*model.Tx) error {
+	stakes := make(map[stakeKey]bool)
+	pools := make(map[positionKey]bool)
+	for _, tx := range txs {
+		switch tx.Type {
+		case model.TxTypeStake, model.TxTypeUnstake:
+			stakes[stakeKey{tx.FromWallet, tx.Coin}] = true
+		case model.TxTypeLPAdd, model.TxTypeLPRemove:
+			pools[positionKey{tx.FromWallet, tx.Pool}] = true
+		}
+	}
+	if len(stakes) == 0 && len(pools) == 0 {
+		return nil
+	}
+
+	stakedAmounts := make(map[stakeKey]decimal.Decimal)
+	lpTokens := make(map[positionKey]decimal.Decimal)
+	for _, tx := range s.journal() {
+		staking := stakeKey{tx.FromWallet, tx.Coin}
+		poolKey := positionKey{tx.FromWallet, tx.Pool}
+		switch {
+		case tx.Type == model.TxTypeStake && stakes[staking]:
+			stakedAmounts[staking] = stakedAmounts[staking].Add(tx.Amount)
+
+		case tx.Type == model.TxTypeUnstake && stakes[staking]:
+			held := stakedAmounts[staking]
+			if tx.Amount.GreaterThan(held) {
+				return fmt.Errorf("cannot unstake %s %s on %s: wallet '%s' has %s staked", tx.Amount, tx.Coin, tx.Date.Format("2006-01-02"), tx.FromWallet, held)
+			}
+			stakedAmounts[staking] = held.Sub(tx.Amount)
+
+		case tx.Type == model.TxTypeLPAdd && pools[poolKey]:
+			lpTokens[poolKey] = lpTokens[poolKey].Add(tx.LPTokens)
+
+		case tx.Type == model.TxTypeLPRemove && pools[poolKey]:
+			held := lpTokens[poolKey]
+			if tx.LPTokens.GreaterThan(held) {
+				return fmt.Errorf("cannot remove %s LP tokens on %s: wallet '%s' holds %s in pool '%s'", tx.LPTokens, tx.Date.Format("2006-01-02"), tx.FromWallet, held, tx.Pool)
+			}
+			lpTokens[poolKey] = held.Sub(tx.LPTokens)
+		}
+	}
+	return nil
+}
+
+// txWallets returns the names of the wallets a transaction touches
+func txWallets(tx *model.Tx) []string {
+	var names []string
+	for _, delta := range txDeltas(tx) {
+		names = append(names, delta.wallet)
+	}
+	return names
+}
+
+// walletNames returns the names of all wallets
+func (s *Storage) walletNames() []string {
+	names := make([]string, 0, len(s.data.Wallets))
+	for name := range s.data.Wallets {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Rebuild recomputes every wallet balance from the transaction journal and
+// returns the differences from the cached balances. With dryRun the
+// cached balances are left untouched.
+func (s *Storage) Rebuild(dryRun bool) ([]BalanceChange, error) {
+	if dryRun {
+		saved := make(map[string][]*model.Balance, len(s.data.Wallets))
+		for name, wallet := range s.data.Wallets {
+			saved[name] = wallet.Balances
+		}
+		defer func() {
+			for name, balances := range saved {
+				s.data.Wallets[name].Balances = balances
+			}
+		}()
+		return s.refreshBalances(s.walletNames()...), nil
+	}
+
+	changes := s.refreshBalances(s.walletNames()...)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, s.save()
+}
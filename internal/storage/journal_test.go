@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// dec parses a decimal literal for test tables
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+// day returns midnight UTC of the given date
+func day(date string) time.Time {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// newTestStorage returns an in-memory ledger holding the given wallets and
+// transactions; it has no backend, so nothing can be saved
+func newTestStorage(wallets []*model.Wallet, txs ...*model.Tx) *Storage {
+	s := &Storage{data: newData(), txIndex: make(map[string]bool), dirty: make(map[entityKey]bool)}
+	for _, wallet := range wallets {
+		s.data.Wallets[wallet.Name] = wallet
+	}
+	for _, tx := range txs {
+		s.data.Transactions[tx.ID] = tx
+		s.txIndex[tx.ID] = true
+	}
+	return s
+}
+
+// formatDeltas renders deltas as "wallet coin amount" lines, with staked
+// balances marked
+func formatDeltas(deltas []balanceDelta) []string {
+	lines := []string{}
+	for _, delta := range deltas {
+		line := fmt.Sprintf("%s %s %s", delta.wallet, delta.coin, delta.amount)
+		if delta.staked {
+			line += " staked"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestTxDeltas(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   *model.Tx
+		want []string
+	}{
+		{
+			name: "deposit pays its fee from the receiver",
+			tx:   &model.Tx{Type: model.TxTypeDeposit, ToWallet: "a", Coin: "ETH", Amount: dec("1.5"), Fee: dec("0.01")},
+			want: []string{"a ETH 1.5", "a ETH -0.01"},
+		},
+		{
+			name: "withdrawal",
+			tx:   &model.Tx{Type: model.TxTypeWithdraw, FromWallet: "a", Coin: "ETH", Amount: dec("2")},
+			want: []string{"a ETH -2"},
+		},
+		{
+			name: "transfer with a fee in another coin and wallet",
+			tx:   &model.Tx{Type: model.TxTypeTransfer, FromWallet: "a", ToWallet: "b", Coin: "USDC", Amount: dec("100"), Fee: dec("0.002"), FeeCoin: "ETH", FeeWallet: "c"},
+			want: []string{"a USDC -100", "b USDC 100", "c ETH -0.002"},
+		},
+		{
+			name: "transfer from a contact",
+			tx:   &model.Tx{Type: model.TxTypeTransfer, ToWallet: "b", Coin: "SOL", Amount: dec("3"), Fee: dec("0.1")},
+			want: []string{"b SOL 3", "b SOL -0.1"},
+		},
+		{
+			name: "swap pays its fee in the sold coin",
+			tx:   &model.Tx{Type: model.TxTypeSwap, SwapWallet: "a", SellCoin: "USDC", SellAmount: dec("200"), BuyCoin: "ETH", BuyAmount: dec("0.1"), Fee: dec("1")},
+			want: []string{"a USDC -200", "a ETH 0.1", "a USDC -1"},
+		},
+		{
+			name: "bridge receiving a wrapped coin",
+			tx:   &model.Tx{Type: model.TxTypeBridge, FromWallet: "a", ToWallet: "b", Coin: "ETH", Amount: dec("1"), ReceivedCoin: "WETH", ReceivedAmount: dec("0.998")},
+			want: []string{"a ETH -1", "b WETH 0.998"},
+		},
+		{
+			name: "bridge receiving the same coin",
+			tx:   &model.Tx{Type: model.TxTypeBridge, FromWallet: "a", ToWallet: "b", Coin: "USDC", Amount: dec("50"), ReceivedAmount: dec("49.5")},
+			want: []string{"a USDC -50", "b USDC 49.5"},
+		},
+		{
+			name: "stake",
+			tx:   &model.Tx{Type: model.TxTypeStake, FromWallet: "a", Coin: "SOL", Amount: dec("10")},
+			want: []string{"a SOL -10", "a SOL 10 staked"},
+		},
+		{
+			name: "unstake",
+			tx:   &model.Tx{Type: model.TxTypeUnstake, FromWallet: "a", Coin: "SOL", Amount: dec("4")},
+			want: []string{"a SOL -4 staked", "a SOL 4"},
+		},
+		{
+			name: "reward",
+			tx:   &model.Tx{Type: model.TxTypeReward, FromWallet: "validator", ToWallet: "a", Coin: "SOL", Amount: dec("0.25")},
+			want: []string{"a SOL 0.25"},
+		},
+		{
+			name: "lp-add",
+			tx:   &model.Tx{Type: model.TxTypeLPAdd, FromWallet: "a", Pool: "uni", Coin: "ETH", Amount: dec("1"), PairCoin: "USDC", PairAmount: dec("3000"), LPTokens: dec("10")},
+			want: []string{"a ETH -1", "a USDC -3000"},
+		},
+		{
+			name: "lp-remove",
+			tx:   &model.Tx{Type: model.TxTypeLPRemove, FromWallet: "a", Pool: "uni", Coin: "ETH", Amount: dec("1.1"), PairCoin: "USDC", PairAmount: dec("2900"), LPTokens: dec("10")},
+			want: []string{"a ETH 1.1", "a USDC 2900"},
+		},
+		{
+			name: "multi-leg pays its fee from the first sending leg",
+			tx: &model.Tx{Type: model.TxTypeMulti, Fee: dec("0.01"), Legs: []model.Leg{
+				{Wallet: "b", Coin: "ARB", Amount: dec("100")},
+				{Wallet: "a", Coin: "ETH", Amount: dec("-0.5")},
+			}},
+			want: []string{"b ARB 100", "a ETH -0.5", "a ETH -0.01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatDeltas(txDeltas(tt.tx)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("txDeltas() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayBalances(t *testing.T) {
+	wallets := func() []*model.Wallet {
+		return []*model.Wallet{
+			// A cached coin without transactions is dropped by the replay
+			{Name: "a", Balances: []*model.Balance{{Coin: "DOGE", Amount: dec("5")}, {Coin: "SOL", Amount: dec("1")}}},
+			{Name: "b"},
+		}
+	}
+	txs := []*model.Tx{
+		{ID: "t3", Type: model.TxTypeTransfer, FromWallet: "a", ToWallet: "b", Coin: "SOL", Amount: dec("2"), Fee: dec("0.000005"), Date: day("2024-03-01")},
+		{ID: "t1", Type: model.TxTypeDeposit, ToWallet: "a", Coin: "SOL", Amount: dec("10"), Date: day("2024-01-01")},
+		{ID: "t2", Type: model.TxTypeStake, FromWallet: "a", Coin: "SOL", Amount: dec("5"), Date: day("2024-02-01")},
+		{ID: "t4", Type: model.TxTypeDeposit, ToWallet: "a", Coin: "USDC", Amount: dec("0.1"), Date: day("2024-03-02")},
+		{ID: "t5", Type: model.TxTypeDeposit, ToWallet: "a", Coin: "USDC", Amount: dec("0.2"), Date: day("2024-03-02")},
+		{ID: "t6", Type: model.TxTypeDeposit, ToWallet: "gone", Coin: "SOL", Amount: dec("1"), Date: day("2024-03-03")},
+	}
+
+	tests := []struct {
+		name  string
+		names []string
+		want  map[string][]string
+	}{
+		{
+			name:  "all wallets",
+			names: []string{"a", "b"},
+			want: map[string][]string{
+				"a": {"SOL 2.999995", "SOL 5 staked", "USDC 0.3"},
+				"b": {"SOL 2"},
+			},
+		},
+		{
+			name:  "only the wallets asked for",
+			names: []string{"b"},
+			want:  map[string][]string{"b": {"SOL 2"}},
+		},
+		{
+			name:  "deleted wallets are skipped",
+			names: []string{"gone"},
+			want:  map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(wallets(), txs...)
+			got := make(map[string][]string)
+			for name, balances := range s.replayBalances(tt.names...) {
+				lines := []string{}
+				for _, bal := range balances {
+					line := fmt.Sprintf("%s %s", bal.Coin, bal.Amount)
+					if bal.Staked {
+						line += " staked"
+					}
+					lines = append(lines, line)
+				}
+				got[name] = lines
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayBalances() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalanceAt(t *testing.T) {
+	s := newTestStorage([]*model.Wallet{{Name: "a"}},
+		&model.Tx{ID: "t1", Type: model.TxTypeDeposit, ToWallet: "a", Coin: "ETH", Amount: dec("1"), Date: day("2024-01-01")},
+		&model.Tx{ID: "t2", Type: model.TxTypeDeposit, ToWallet: "a", Coin: "ETH", Amount: dec("2"), Date: day("2024-02-01")},
+		&model.Tx{ID: "t3", Type: model.TxTypeStake, FromWallet: "a", Coin: "ETH", Amount: dec("0.5"), Date: day("2024-03-01")},
+	)
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{day("2023-12-31"), "0"},
+		{day("2024-01-01"), "1"},
+		{day("2024-02-15"), "3"},
+		{day("2024-03-01"), "2.5"},
+	}
+	for _, tt := range tests {
+		if got := s.BalanceAt("a", "ETH", tt.at); !got.Equal(dec(tt.want)) {
+			t.Errorf("BalanceAt(%s) = %s, want %s", tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// JSONStore keeps the ledger in a single wago.json file, rewritten
+// atomically on every save. It is the only store that can be encrypted.
+type JSONStore struct {
+	*Storage
+	file string
+}
+
+var _ Store = (*JSONStore)(nil)
+
+// newJSONStore persists s to the JSON file at file
+func newJSONStore(s *Storage, file string) *JSONStore {
+	b := &JSONStore{Storage: s, file: file}
+	s.backend = b
+	return b
+}
+
+// Backend returns the name of the backend holding the ledger
+func (b *JSONStore) Backend() string { return BackendJSON }
+
+func (b *JSONStore) path() string { return b.file }
+
+func (b *JSONStore) read() ([]byte, error) {
+	raw, err := os.ReadFile(b.file)
+	if os.IsNotExist(err) || (err == nil && len(raw) == 0) {
+		return nil, nil
+	}
+	return raw, err
+}
+
+// write renders the whole ledger, whatever changed
+func (b *JSONStore) write(changes []entityChange) error {
+	doc, err := json.Marshal(b.data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	data, perm, err := b.renderDoc(doc)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(b.file, data, perm); err != nil {
+		return fmt.Errorf("failed to write data file: %w", err)
+	}
+	return nil
+}
+
+func (b *JSONStore) incremental() bool { return false }
+
+func (b *JSONStore) closeBackend() error { return nil }
+
+// Encrypt re-saves the ledger encrypted with a key derived from passphrase
+func (b *JSONStore) Encrypt(passphrase string) error {
+	if b.batch {
+		return errBatchInProgress
+	}
+
+	key, err := newLedgerKey(passphrase)
+	if err != nil {
+		return err
+	}
+	b.key = key
+	return b.reseal()
+}
+
+// Decrypt re-saves the ledger as plain JSON
+func (b *JSONStore) Decrypt() error {
+	if b.batch {
+		return errBatchInProgress
+	}
+
+	b.key = nil
+	return b.reseal()
+}
+
+// reseal rewrites the ledger, its copies and its undo history after the
+// key changed, so all of them are stored the same way
+func (b *JSONStore) reseal() error {
+	if err := b.save(); err != nil {
+		return err
+	}
+	if err := b.resealCopies(); err != nil {
+		return err
+	}
+	return b.resealHistory()
+}
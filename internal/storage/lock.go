@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lockFileName      = "wago.lock"
+	lockTimeout       = 10 * time.Second
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// errLockHeld is returned by tryLockFile when another process holds the lock
+var errLockHeld = errors.New("lock held by another process")
+
+// fileLock is an advisory lock on a data directory. Locks are reference
+// counted per process so nested storage.New calls (for example from
+// util.GetCoinPrices) don't deadlock against their own process.
+type fileLock struct {
+	path string
+	file *os.File
+	refs int
+}
+
+var (
+	locksMu sync.Mutex
+	locks   = make(map[string]*fileLock)
+)
+
+// acquireLock takes the lock at path, waiting up to lockTimeout for another
+// wago process to release it
+func acquireLock(path string) (*fileLock, error) {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	if l, ok := locks[path]; ok {
+		l.refs++
+		return l, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockHeld) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock data file: %w", err)
+		}
+		if time.Now().After(deadline) {
+			holder := lockHolder(f)
+			f.Close()
+			return nil, fmt.Errorf("data file is locked by another wago process%s; close it and try again", holder)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// Record our pid so a waiting process can say who holds the lock
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	l := &fileLock{path: path, file: f, refs: 1}
+	locks[path] = l
+	return l, nil
+}
+
+// release drops one reference and unlocks once the last one is gone
+func (l *fileLock) release() error {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	l.refs--
+	if l.refs > 0 {
+		return nil
+	}
+
+	delete(locks, l.path)
+	unlockErr := unlockFile(l.file)
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	return unlockErr
+}
+
+// lockHolder describes the process recorded in the lock file, if any
+func lockHolder(f *os.File) string {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 32))
+	if err != nil {
+		return ""
+	}
+	pid := strings.TrimSpace(string(data))
+	if pid == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (pid %s)", pid)
+}
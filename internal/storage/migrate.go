@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CurrentSchemaVersion is the wago.json schema written by this build
+const CurrentSchemaVersion = 3
+
+// migration upgrades a decoded document from schema version from to from+1
+type migration struct {
+	from        int
+	description string
+	apply       func(doc map[string]interface{}) error
+}
+
+// migrations holds every upgrade step in order. To change the file format,
+// append a step here and bump CurrentSchemaVersion.
+var migrations = []migration{
+	{
+		from:        0,
+		description: "add schema_version",
+		apply: func(doc map[string]interface{}) error {
+			// Unversioned files already match schema 1
+			return nil
+		},
+	},
+	{
+		from:        1,
+		description: "store amounts as decimal strings",
+		apply: func(doc map[string]interface{}) error {
+			if txs, ok := doc["transactions"].(map[string]interface{}); ok {
+				for _, tx := range txs {
+					if tx, ok := tx.(map[string]interface{}); ok {
+						numbersToStrings(tx, "amount", "fee", "sell_amount", "buy_amount")
+					}
+				}
+			}
+			if wallets, ok := doc["wallets"].(map[string]interface{}); ok {
+				for _, wallet := range wallets {
+					wallet, ok := wallet.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					balances, _ := wallet["balances"].([]interface{})
+					for _, bal := range balances {
+						if bal, ok := bal.(map[string]interface{}); ok {
+							numbersToStrings(bal, "amount")
+						}
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		from:        2,
+		description: "charge transfer fees to an explicit wallet and coin",
+		apply: func(doc map[string]interface{}) error {
+			// Transfer fees used to be deducted from the received amount;
+			// the receiver paying in the sent coin has the same effect
+			txs, _ := doc["transactions"].(map[string]interface{})
+			for _, tx := range txs {
+				tx, ok := tx.(map[string]interface{})
+				if !ok || tx["type"] != "transfer" {
+					continue
+				}
+				fee, _ := tx["fee"].(string)
+				if amount, err := decimal.NewFromString(fee); err != nil || amount.IsZero() {
+					continue
+				}
+				if to, _ := tx["to_wallet"].(string); to != "" {
+					tx["fee_wallet"] = to
+					tx["fee_coin"] = tx["coin"]
+				} else {
+					// Without a receiver the fee never affected a balance
+					tx["fee"] = "0"
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// numbersToStrings replaces the given JSON number fields of obj with their
+// literal text, so "0.1" stays exactly 0.1 instead of the nearest float
+func numbersToStrings(obj map[string]interface{}, fields ...string) {
+	for _, field := range fields {
+		if n, ok := obj[field].(json.Number); ok {
+			obj[field] = n.String()
+		}
+	}
+}
+
+// schemaVersion reads the schema_version field of a raw document;
+// files written before versioning report 0
+func schemaVersion(raw []byte) (int, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return 0, fmt.Errorf("failed to parse data file: %w", err)
+	}
+	return header.SchemaVersion, nil
+}
+
+// migrate upgrades raw from version to CurrentSchemaVersion one step at a time
+func migrate(raw []byte, version int) ([]byte, error) {
+	if version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("data file uses schema v%d but this wago only supports up to v%d; please upgrade wago", version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return raw, nil
+	}
+
+	// Decode numbers as json.Number so amounts survive the round trip untouched
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse data file: %w", err)
+	}
+
+	for version < CurrentSchemaVersion {
+		step, ok := findMigration(version)
+		if !ok {
+			return nil, fmt.Errorf("no migration from schema v%d", version)
+		}
+		if err := step.apply(doc); err != nil {
+			return nil, fmt.Errorf("migration from schema v%d (%s) failed: %w", version, step.description, err)
+		}
+		version++
+		doc["schema_version"] = version
+	}
+
+	return json.Marshal(doc)
+}
+
+// findMigration returns the step that upgrades from the given version
+func findMigration(from int) (migration, bool) {
+	for _, m := range migrations {
+		if m.from == from {
+			return m, true
+		}
+	}
+	return migration{}, false
+}
+
+// writeMigrationBackup keeps a copy of the file as it was before migrating,
+// encrypted when the ledger is
+func (s *Storage) writeMigrationBackup(raw []byte, version int) error {
+	path := fmt.Sprintf("%s.v%d-%s.bak", filepath.Join(s.dataDir, dataFileName), version, time.Now().Format("20060102-150405"))
+	if s.key != nil && !isEncrypted(raw) {
+		var err error
+		if raw, err = s.key.seal(raw); err != nil {
+			return fmt.Errorf("failed to encrypt pre-migration backup: %w", err)
+		}
+	}
+	if err := writeFileAtomic(path, raw, secretFileMode); err != nil {
+		return fmt.Errorf("failed to write pre-migration backup: %w", err)
+	}
+	return nil
+}
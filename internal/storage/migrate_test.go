@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		version int
+		want    map[string]interface{} // transaction or wallet fields after migrating, by "kind/id/field"
+		wantErr string
+	}{
+		{
+			name:    "unversioned file",
+			raw:     `{"wallets":{},"transactions":{}}`,
+			version: 0,
+		},
+		{
+			name:    "v1 amounts become exact decimal strings",
+			raw:     `{"schema_version":1,"transactions":{"t1":{"type":"deposit","amount":0.1,"fee":0.30000000000000004,"sell_amount":1e-8}},"wallets":{"w1":{"balances":[{"coin":"ETH","amount":12.345678901234567}]}}}`,
+			version: 1,
+			want: map[string]interface{}{
+				"transactions/t1/amount":      "0.1",
+				"transactions/t1/fee":         "0.30000000000000004",
+				"transactions/t1/sell_amount": "1e-8",
+				"wallets/w1/balance":          "12.345678901234567",
+			},
+		},
+		{
+			name:    "v2 transfer fee is charged to the receiver",
+			raw:     `{"schema_version":2,"transactions":{"t1":{"type":"transfer","from_wallet":"a","to_wallet":"b","coin":"ETH","amount":"1","fee":"0.01"}}}`,
+			version: 2,
+			want: map[string]interface{}{
+				"transactions/t1/fee":        "0.01",
+				"transactions/t1/fee_wallet": "b",
+				"transactions/t1/fee_coin":   "ETH",
+			},
+		},
+		{
+			name:    "v2 transfer fee without a receiver is dropped",
+			raw:     `{"schema_version":2,"transactions":{"t1":{"type":"transfer","from_wallet":"a","coin":"ETH","amount":"1","fee":"0.01"}}}`,
+			version: 2,
+			want: map[string]interface{}{
+				"transactions/t1/fee":        "0",
+				"transactions/t1/fee_wallet": nil,
+			},
+		},
+		{
+			name:    "v2 zero fees and other types are left alone",
+			raw:     `{"schema_version":2,"transactions":{"t1":{"type":"transfer","to_wallet":"b","coin":"ETH","fee":"0"},"t2":{"type":"withdrawal","from_wallet":"a","coin":"ETH","fee":"0.5"}}}`,
+			version: 2,
+			want: map[string]interface{}{
+				"transactions/t1/fee_wallet": nil,
+				"transactions/t2/fee":        "0.5",
+				"transactions/t2/fee_wallet": nil,
+			},
+		},
+		{
+			name:    "v1 runs every later step",
+			raw:     `{"schema_version":1,"transactions":{"t1":{"type":"transfer","to_wallet":"b","coin":"SOL","amount":2,"fee":0.000005}}}`,
+			version: 1,
+			want: map[string]interface{}{
+				"transactions/t1/fee":        "0.000005",
+				"transactions/t1/fee_wallet": "b",
+				"transactions/t1/fee_coin":   "SOL",
+			},
+		},
+		{
+			name:    "newer schema",
+			raw:     `{"schema_version":99}`,
+			version: 99,
+			wantErr: "please upgrade wago",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := migrate([]byte(tt.raw), tt.version)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("migrate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrate() error = %v", err)
+			}
+
+			version, err := schemaVersion(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != CurrentSchemaVersion {
+				t.Errorf("schema_version = %d, want %d", version, CurrentSchemaVersion)
+			}
+
+			var doc map[string]interface{}
+			if err := json.Unmarshal(out, &doc); err != nil {
+				t.Fatal(err)
+			}
+			for path, want := range tt.want {
+				if got := migratedField(doc, path); got != want {
+					t.Errorf("%s = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateCurrentVersionUnchanged(t *testing.T) {
+	raw := []byte(`{"schema_version":3,"transactions":{"t1":{"amount":"0.1"}}}`)
+	out, err := migrate(raw, CurrentSchemaVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(raw) {
+		t.Errorf("migrate() = %s, want the input unchanged", out)
+	}
+}
+
+// migratedField looks up "kind/id/field" in a decoded document; the
+// "balance" field of a wallet is the amount of its first balance
+func migratedField(doc map[string]interface{}, path string) interface{} {
+	parts := strings.Split(path, "/")
+	entities, _ := doc[parts[0]].(map[string]interface{})
+	entity, _ := entities[parts[1]].(map[string]interface{})
+	if parts[2] == "balance" {
+		balances, _ := entity["balances"].([]interface{})
+		if len(balances) == 0 {
+			return nil
+		}
+		return balances[0].(map[string]interface{})["amount"]
+	}
+	return entity[parts[2]]
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+const (
+	// DefaultProfile names the ledger stored directly in the data directory
+	DefaultProfile = "default"
+
+	profilesDirName = "profiles"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Options controls where New looks for the ledger
+type Options struct {
+	DataDir string // Base data directory; falls back to $WAGO_HOME, then ~/.wago
+	Profile string // Named profile; empty or "default" selects the main ledger
+	KeyFile string // File holding the passphrase of an encrypted ledger
+}
+
+var options Options
+
+// Configure sets the options used by subsequent calls to New
+func Configure(opts Options) error {
+	if opts.Profile == "" {
+		opts.Profile = os.Getenv("WAGO_PROFILE")
+	}
+	if err := validateProfile(opts.Profile); err != nil {
+		return err
+	}
+	options = opts
+	return nil
+}
+
+// SetProfile switches the profile used by subsequent calls to New
+func SetProfile(name string) error {
+	if err := validateProfile(name); err != nil {
+		return err
+	}
+	options.Profile = name
+	return nil
+}
+
+// CurrentProfile returns the name of the active profile
+func CurrentProfile() string {
+	if options.Profile == "" {
+		return DefaultProfile
+	}
+	return options.Profile
+}
+
+// ListProfiles returns the default profile followed by all named profiles
+// found in the data directory
+func ListProfiles() ([]string, error) {
+	baseDir, err := baseDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []string{}
+	entries, err := os.ReadDir(filepath.Join(baseDir, profilesDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) && entry.Name() != DefaultProfile {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	sort.Strings(profiles)
+
+	return append([]string{DefaultProfile}, profiles...), nil
+}
+
+// validateProfile rejects names that can't be used as a directory name
+func validateProfile(name string) error {
+	if name == "" || profileNamePattern.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf("invalid profile name '%s': use letters, digits, '-' and '_'", name)
+}
+
+// baseDataDir returns the directory holding the default ledger and all profiles
+func baseDataDir() (string, error) {
+	if options.DataDir != "" {
+		return options.DataDir, nil
+	}
+	if dir := os.Getenv("WAGO_HOME"); dir != "" {
+		return dir, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".wago"), nil
+}
+
+// profileDataDir returns the directory holding the active profile's ledger
+func profileDataDir() (string, error) {
+	baseDir, err := baseDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	profile := CurrentProfile()
+	if profile == DefaultProfile {
+		return baseDir, nil
+	}
+	return filepath.Join(baseDir, profilesDirName, profile), nil
+}
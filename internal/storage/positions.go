@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// positionKey identifies a liquidity pool position
+type positionKey struct {
+	wallet string
+	pool   string
+}
+
+// ListPositions replays the open liquidity pool positions of wallet, or of
+// every wallet when wallet is empty, ordered by wallet and pool
+func (s *Storage) ListPositions(wallet string) []*model.Position {
+	var positions []*model.Position
+	for key, pos := range s.replayPositions("") {
+		if (wallet == "" || key.wallet == wallet) && pos.LPTokens.IsPositive() {
+			positions = append(positions, pos)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Wallet != positions[j].Wallet {
+			return positions[i].Wallet < positions[j].Wallet
+		}
+		return positions[i].Pool < positions[j].Pool
+	})
+	return positions
+}
+
+// position returns the position of wallet in pool, or nil, leaving out the
+// transaction with ID except, e.g. one being edited
+func (s *Storage) position(wallet, pool, except string) *model.Position {
+	return s.replayPositions(except)[positionKey{wallet, pool}]
+}
+
+// replayPositions folds the lp-add and lp-remove transactions of the
+// journal into positions. Removing LP tokens releases the same share of
+// the underlying amounts and cost basis.
+func (s *Storage) replayPositions(except string) map[positionKey]*model.Position {
+	positions := make(map[positionKey]*model.Position)
+	for _, tx := range s.journal() {
+		if tx.ID == except || (tx.Type != model.TxTypeLPAdd && tx.Type != model.TxTypeLPRemove) {
+			continue
+		}
+		key := positionKey{tx.FromWallet, tx.Pool}
+		pos := positions[key]
+		// A closed position reopens from scratch, possibly with other coins
+		if pos == nil || (tx.Type == model.TxTypeLPAdd && !pos.LPTokens.IsPositive()) {
+			pos = &model.Position{Wallet: tx.FromWallet, Pool: tx.Pool, Coin: tx.Coin, PairCoin: tx.PairCoin}
+			positions[key] = pos
+		}
+
+		if tx.Type == model.TxTypeLPAdd {
+			amount, pairAmount := tx.Amount, tx.PairAmount
+			if tx.Coin == pos.PairCoin && tx.PairCoin == pos.Coin {
+				amount, pairAmount = pairAmount, amount
+			}
+			pos.Amount = pos.Amount.Add(amount)
+			pos.PairAmount = pos.PairAmount.Add(pairAmount)
+			pos.LPTokens = pos.LPTokens.Add(tx.LPTokens)
+			pos.CostBasis = pos.CostBasis.Add(tx.CostBasis)
+			continue
+		}
+
+		if !pos.LPTokens.IsPositive() {
+			continue
+		}
+		share := decimal.Min(tx.LPTokens.Div(pos.LPTokens), decimal.NewFromInt(1))
+		keep := decimal.NewFromInt(1).Sub(share)
+		pos.Amount = pos.Amount.Mul(keep)
+		pos.PairAmount = pos.PairAmount.Mul(keep)
+		pos.LPTokens = pos.LPTokens.Sub(tx.LPTokens)
+		pos.CostBasis = pos.CostBasis.Mul(keep)
+	}
+	return positions
+}
+
+// PositionValue returns the USD value of a position's underlying assets at
+// prices, and whether both coins have a price
+func PositionValue(pos *model.Position, prices map[string]float64) (decimal.Decimal, bool) {
+	price, priced := prices[strings.ToLower(pos.Coin)]
+	pairPrice, pairPriced := prices[strings.ToLower(pos.PairCoin)]
+	value := pos.Amount.Mul(decimal.NewFromFloat(price)).Add(pos.PairAmount.Mul(decimal.NewFromFloat(pairPrice)))
+	return value, priced && pairPriced
+}
+
+// depositValue returns the USD value of what an lp-add deposits at the
+// current prices
+func (s *Storage) depositValue(tx *model.Tx) decimal.Decimal {
+	value, _ := PositionValue(&model.Position{
+		Coin:       tx.Coin,
+		Amount:     tx.Amount,
+		PairCoin:   tx.PairCoin,
+		PairAmount: tx.PairAmount,
+	}, s.data.Prices)
+	return value
+}
+
+// validateLP checks an lp-add or lp-remove: a wallet, a pool, two coins and
+// LP tokens, with the coins matching an existing position in the pool
+func (s *Storage) validateLP(tx *model.Tx) error {
+	if _, err := s.GetWallet(tx.FromWallet); err != nil {
+		return err
+	}
+	if tx.Pool == "" {
+		return fmt.Errorf("%s transaction needs a pool", tx.Type)
+	}
+	if tx.Coin == "" || tx.PairCoin == "" {
+		return fmt.Errorf("%s transaction needs both coins of the pool", tx.Type)
+	}
+	if !tx.LPTokens.IsPositive() {
+		return fmt.Errorf("%s LP token count must be positive", tx.Type)
+	}
+	if tx.Type == model.TxTypeLPAdd && (!tx.Amount.IsPositive() || !tx.PairAmount.IsPositive()) {
+		return fmt.Errorf("lp-add amounts must be positive")
+	}
+	if tx.Amount.IsNegative() || tx.PairAmount.IsNegative() {
+		return fmt.Errorf("lp-remove amounts must not be negative")
+	}
+
+	if pos := s.position(tx.FromWallet, tx.Pool, tx.ID); pos != nil && pos.LPTokens.IsPositive() {
+		same := tx.Coin == pos.Coin && tx.PairCoin == pos.PairCoin
+		swapped := tx.Coin == pos.PairCoin && tx.PairCoin == pos.Coin
+		if !same && !swapped {
+			return fmt.Errorf("pool '%s' of wallet '%s' holds %s/%s", tx.Pool, tx.FromWallet, pos.Coin, pos.PairCoin)
+		}
+	}
+	return nil
+}
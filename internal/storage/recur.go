@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurrence yields the occurrences of a schedule
+type recurrence interface {
+	// next returns the first occurrence after t, or the zero time if
+	// there is none
+	next(t time.Time) time.Time
+}
+
+// intervalAliases are the named intervals a rule may use
+var intervalAliases = map[string]string{
+	"daily":   "1d",
+	"weekly":  "1w",
+	"monthly": "1m",
+	"yearly":  "1y",
+}
+
+var intervalPattern = regexp.MustCompile(`^(\d+)([dwmy])$`)
+
+// parseRule parses a schedule rule starting at start: an interval like 2w
+// or monthly, or a five-field cron expression like "0 9 * * 1", evaluated
+// in start's time zone
+func parseRule(rule string, start time.Time) (recurrence, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	if len(strings.Fields(rule)) == 5 {
+		return parseCron(rule, start)
+	}
+
+	if alias, ok := intervalAliases[rule]; ok {
+		rule = alias
+	}
+	match := intervalPattern.FindStringSubmatch(rule)
+	if match == nil {
+		return nil, fmt.Errorf("invalid rule '%s': use an interval like 1d, 2w, 1m or 1y, or a cron expression like \"0 9 * * 1\"", rule)
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid rule '%s': the interval must be positive", rule)
+	}
+
+	r := intervalRecurrence{start: start}
+	switch match[2] {
+	case "d":
+		r.days = n
+	case "w":
+		r.days = 7 * n
+	case "m":
+		r.months = n
+	case "y":
+		r.months = 12 * n
+	}
+	return r, nil
+}
+
+// intervalRecurrence repeats every few days or months from start. Monthly
+// occurrences keep start's day, or the last day of shorter months.
+type intervalRecurrence struct {
+	start  time.Time
+	days   int
+	months int
+}
+
+// at returns occurrence k, counting from 0 at start
+func (r intervalRecurrence) at(k int) time.Time {
+	if r.months > 0 {
+		return addMonths(r.start, k*r.months)
+	}
+	return r.start.AddDate(0, 0, k*r.days)
+}
+
+func (r intervalRecurrence) next(t time.Time) time.Time {
+	if t.Before(r.start) {
+		return r.start
+	}
+
+	// Estimate low and step forward; days shift by an hour around DST
+	// changes and months are at most 31 days long
+	period := time.Duration(r.days) * 24 * time.Hour
+	if r.months > 0 {
+		period = time.Duration(r.months) * 31 * 24 * time.Hour
+	}
+	k := int(t.Sub(r.start)/period) - 1
+	if k < 0 {
+		k = 0
+	}
+	for !r.at(k).After(t) {
+		k++
+	}
+	return r.at(k)
+}
+
+// addMonths adds n months to t, clamping the day to the target month
+func addMonths(t time.Time, n int) time.Time {
+	year, month, day := t.Date()
+	last := time.Date(year, month+time.Month(n)+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month+time.Month(n), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// cronRecurrence matches minute, hour, day of month, month and day of week
+// like cron. As in cron, a day matches either day field when both are
+// restricted.
+type cronRecurrence struct {
+	start                            time.Time
+	minutes, hours, days, months, wd uint64
+	anyDay, anyWeekday               bool
+}
+
+// cronSearchYears bounds the search for a matching time, so rules like
+// "0 0 30 2 *" that never match end instead of looping forever
+const cronSearchYears = 5
+
+func parseCron(rule string, start time.Time) (recurrence, error) {
+	fields := strings.Fields(rule)
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule '%s': %v", rule, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return cronRecurrence{
+		start:      start,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		wd:         sets[4],
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of *, N, N-M, optionally
+// with a /STEP, into a bit set
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in '%s'", part)
+			}
+			step, part = n, part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			n, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", part)
+			}
+			lo, hi = n, n
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("'%s' is out of range %d-%d", part, min, max)
+		}
+
+		for n := lo; n <= hi; n += step {
+			set |= 1 << uint(n)
+		}
+	}
+	return set, nil
+}
+
+func (r cronRecurrence) next(t time.Time) time.Time {
+	if t.Before(r.start) {
+		t = r.start.Add(-time.Nanosecond)
+	}
+	t = t.In(r.start.Location())
+
+	// Start at the next whole minute
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case r.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !r.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case r.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case r.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches checks the day of month and day of week fields
+func (r cronRecurrence) dayMatches(t time.Time) bool {
+	day := r.days&(1<<uint(t.Day())) != 0
+	weekday := r.wd&(1<<uint(t.Weekday())) != 0
+	switch {
+	case r.anyDay && r.anyWeekday:
+		return true
+	case r.anyDay:
+		return weekday
+	case r.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// RenameWallet renames a wallet and rewrites every transaction that
+// references it, saving both in one change
+func (s *Storage) RenameWallet(oldName, newName string) error {
+	wallet, exists := s.data.Wallets[oldName]
+	if !exists {
+		return fmt.Errorf("wallet with name '%s' not found", oldName)
+	}
+	if err := s.checkNewName(oldName, newName); err != nil {
+		return err
+	}
+
+	delete(s.data.Wallets, oldName)
+	wallet.Name = newName
+	s.data.Wallets[newName] = wallet
+	s.touch(kindWallets, oldName, newName)
+	s.renameTxRefs(oldName, newName)
+	return s.save()
+}
+
+// RenameContact renames a contact and rewrites the transfers that use it
+// as a counterparty
+func (s *Storage) RenameContact(oldName, newName string) error {
+	contact, exists := s.data.Contacts[oldName]
+	if !exists {
+		return fmt.Errorf("contact with name '%s' not found", oldName)
+	}
+	if err := s.checkNewName(oldName, newName); err != nil {
+		return err
+	}
+
+	delete(s.data.Contacts, oldName)
+	contact.Name = newName
+	s.data.Contacts[newName] = contact
+	s.touch(kindContacts, oldName, newName)
+	// A wallet of the same name takes precedence in transactions
+	if _, isWallet := s.data.Wallets[oldName]; !isWallet {
+		s.renameTxRefs(oldName, newName)
+	}
+	return s.save()
+}
+
+// RenameCategory renames a category and moves its wallets along
+func (s *Storage) RenameCategory(oldName, newName string) error {
+	category, exists := s.data.Categories[oldName]
+	if !exists {
+		return fmt.Errorf("category with name '%s' not found", oldName)
+	}
+	if newName == "" {
+		return fmt.Errorf("new name must not be empty")
+	}
+	if _, exists := s.data.Categories[newName]; exists {
+		return fmt.Errorf("category with name '%s' already exists", newName)
+	}
+
+	delete(s.data.Categories, oldName)
+	category.Name = newName
+	s.data.Categories[newName] = category
+	s.touch(kindCategories, oldName, newName)
+	for _, wallet := range s.data.Wallets {
+		if wallet.Category == oldName {
+			wallet.Category = newName
+			s.touch(kindWallets, wallet.Name)
+		}
+	}
+	return s.save()
+}
+
+// checkNewName validates the new name of a wallet or contact. Both share
+// the transaction counterparty namespace, so the name must be free in both.
+func (s *Storage) checkNewName(oldName, newName string) error {
+	if newName == "" {
+		return fmt.Errorf("new name must not be empty")
+	}
+	if newName == oldName {
+		return fmt.Errorf("'%s' is already named that", oldName)
+	}
+	if _, exists := s.data.Wallets[newName]; exists {
+		return fmt.Errorf("wallet with name '%s' already exists", newName)
+	}
+	if _, exists := s.data.Contacts[newName]; exists {
+		return fmt.Errorf("contact with name '%s' already exists", newName)
+	}
+	return nil
+}
+
+// renameTxRefs points every transaction and schedule referencing oldName
+// at newName
+func (s *Storage) renameTxRefs(oldName, newName string) {
+	renamed := false
+	rename := func(ref *string) {
+		if *ref == oldName {
+			*ref = newName
+			renamed = true
+		}
+	}
+	renameTx := func(tx *model.Tx) bool {
+		renamed = false
+		rename(&tx.FromWallet)
+		rename(&tx.ToWallet)
+		rename(&tx.SwapWallet)
+		rename(&tx.FeeWallet)
+		for i := range tx.Legs {
+			rename(&tx.Legs[i].Wallet)
+		}
+		return renamed
+	}
+
+	for id, tx := range s.data.Transactions {
+		if renameTx(tx) {
+			s.touch(kindTransactions, id)
+		}
+	}
+	for name, sch := range s.data.Schedules {
+		if renameTx(sch.Tx) {
+			s.touch(kindSchedules, name)
+		}
+	}
+}
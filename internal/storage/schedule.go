@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// maxScheduleRun caps the occurrences one run records per schedule, so a
+// frequent rule started long ago can't flood the ledger at once. The next
+// run continues where it stopped.
+const maxScheduleRun = 1000
+
+// AddSchedule adds a recurring transaction. The template is validated like
+// a transaction dated at its start.
+func (s *Storage) AddSchedule(sch *model.Schedule) error {
+	if sch.Name == "" {
+		return fmt.Errorf("schedule name must not be empty")
+	}
+	if _, exists := s.data.Schedules[sch.Name]; exists {
+		return fmt.Errorf("schedule with name '%s' already exists", sch.Name)
+	}
+	if sch.Tx == nil {
+		return fmt.Errorf("schedule '%s' has no transaction", sch.Name)
+	}
+	rule, err := parseRule(sch.Rule, sch.Tx.Date)
+	if err != nil {
+		return err
+	}
+	if firstOccurrence(rule, sch.Tx.Date).IsZero() {
+		return fmt.Errorf("rule '%s' never matches", sch.Rule)
+	}
+	if sch.End != nil && sch.End.Before(sch.Tx.Date) {
+		return fmt.Errorf("schedule '%s' ends before it starts", sch.Name)
+	}
+	if err := s.validateTx(sch.Tx); err != nil {
+		return err
+	}
+	sch.Tx.ID = ""
+
+	s.data.Schedules[sch.Name] = sch
+	s.touch(kindSchedules, sch.Name)
+	return s.save()
+}
+
+// GetSchedule gets a schedule by name
+func (s *Storage) GetSchedule(name string) (*model.Schedule, error) {
+	sch, exists := s.data.Schedules[name]
+	if !exists {
+		return nil, fmt.Errorf("schedule with name '%s' not found", name)
+	}
+	return sch, nil
+}
+
+// DeleteSchedule deletes a schedule, keeping the transactions it recorded
+func (s *Storage) DeleteSchedule(name string) error {
+	if _, exists := s.data.Schedules[name]; !exists {
+		return fmt.Errorf("schedule with name '%s' not found", name)
+	}
+
+	delete(s.data.Schedules, name)
+	s.touch(kindSchedules, name)
+	return s.save()
+}
+
+// ListSchedules returns all schedules
+func (s *Storage) ListSchedules() []*model.Schedule {
+	schedules := make([]*model.Schedule, 0, len(s.data.Schedules))
+	for _, sch := range s.data.Schedules {
+		schedules = append(schedules, sch)
+	}
+	return schedules
+}
+
+// ScheduleDue returns the occurrences of sch not recorded yet, up to now
+// and at most limit of them, and the first occurrence after those, which
+// is zero once the schedule has ended
+func ScheduleDue(sch *model.Schedule, now time.Time, limit int) ([]time.Time, time.Time, error) {
+	rule, err := parseRule(sch.Rule, sch.Tx.Date)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var due []time.Time
+	next := firstOccurrence(rule, sch.Tx.Date)
+	if sch.Last != nil {
+		next = rule.next(*sch.Last)
+	}
+	for !next.IsZero() && (sch.End == nil || !next.After(*sch.End)) {
+		if next.After(now) || len(due) == limit {
+			return due, next, nil
+		}
+		due = append(due, next)
+		next = rule.next(next)
+	}
+	return due, time.Time{}, nil
+}
+
+// RunSchedules records the occurrences of every schedule due by now as
+// transactions, in one change. Occurrence IDs derive from the schedule
+// name and date, so ones already recorded, e.g. by a synced copy of the
+// ledger, are skipped; an ID taken by any other transaction is an error.
+func (s *Storage) RunSchedules(now time.Time) ([]*model.Tx, error) {
+	names := make([]string, 0, len(s.data.Schedules))
+	for name := range s.data.Schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	due := make(map[string][]time.Time)
+	for _, name := range names {
+		occurrences, _, err := ScheduleDue(s.data.Schedules[name], now, maxScheduleRun)
+		if err != nil {
+			return nil, fmt.Errorf("schedule '%s': %w", name, err)
+		}
+		if len(occurrences) > 0 {
+			due[name] = occurrences
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	var recorded []*model.Tx
+	err := s.Update(func(store Store) error {
+		for _, name := range names {
+			sch := s.data.Schedules[name]
+			for _, date := range due[name] {
+				tx := occurrence(sch, date)
+				err := s.AddTransaction(tx)
+				if errors.Is(err, ErrTxExists) {
+					// Recorded already, e.g. by a synced copy of the ledger
+					if existing := s.data.Transactions[tx.ID]; existing.Schedule == name {
+						err = nil
+					}
+				} else if err == nil {
+					recorded = append(recorded, tx)
+				}
+				if err != nil {
+					return fmt.Errorf("schedule '%s' on %s: %w", name, date.Format("2006-01-02 15:04"), err)
+				}
+				last := date
+				sch.Last = &last
+				s.touch(kindSchedules, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// firstOccurrence returns the first occurrence of rule at or after start:
+// start itself for an interval, the first matching time for cron
+func firstOccurrence(rule recurrence, start time.Time) time.Time {
+	return rule.next(start.Add(-time.Nanosecond))
+}
+
+// occurrence copies a schedule's template to a transaction dated date
+func occurrence(sch *model.Schedule, date time.Time) *model.Tx {
+	tx := *sch.Tx
+	tx.Date = date
+	tx.Legs = append([]model.Leg(nil), sch.Tx.Legs...)
+	tx.Tags = append([]string(nil), sch.Tx.Tags...)
+	tx.Schedule = sch.Name
+	tx.ID = scheduleTxID(sch.Name, date)
+	return &tx
+}
+
+// scheduleUses reports whether the template of sch references name
+func scheduleUses(sch *model.Schedule, name string) bool {
+	tx := sch.Tx
+	return tx.FromWallet == name || tx.ToWallet == name || tx.SwapWallet == name || tx.FeeWallet == name || hasLeg(tx, name)
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+const sqliteFileName = "wago.db"
+
+// SQLiteStore keeps each ledger entity as a row of wago.db, so a save
+// only touches the records that changed. The undo history lives in the
+// same database.
+type SQLiteStore struct {
+	*Storage
+	file string
+	db   *sql.DB
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// openSQLiteStore persists s to the database at file, creating it if needed
+func openSQLiteStore(s *Storage, file string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", file+"?_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS entities (
+		kind TEXT NOT NULL,
+		key  TEXT NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (kind, key)
+	);
+	CREATE TABLE IF NOT EXISTS history (
+		seq            INTEGER PRIMARY KEY AUTOINCREMENT,
+		stack          TEXT NOT NULL,
+		schema_version INTEGER NOT NULL,
+		entry          TEXT NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	b := &SQLiteStore{Storage: s, file: file, db: db}
+	s.backend = b
+	return b, nil
+}
+
+// Backend returns the name of the backend holding the ledger
+func (b *SQLiteStore) Backend() string { return BackendSQLite }
+
+func (b *SQLiteStore) path() string { return b.file }
+
+func (b *SQLiteStore) read() ([]byte, error) {
+	rows, err := b.db.Query(`SELECT kind, key, data FROM entities`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+	defer rows.Close()
+
+	entities := make(map[entityKey][]byte)
+	for rows.Next() {
+		var k entityKey
+		var data string
+		if err := rows.Scan(&k.kind, &k.key, &data); err != nil {
+			return nil, fmt.Errorf("failed to read database: %w", err)
+		}
+		entities[k] = []byte(data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return assembleDoc(entities)
+}
+
+func (b *SQLiteStore) write(changes []entityChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		if change.after == nil {
+			_, err = tx.Exec(`DELETE FROM entities WHERE kind = ? AND key = ?`, change.key.kind, change.key.key)
+		} else {
+			_, err = tx.Exec(`INSERT OR REPLACE INTO entities (kind, key, data) VALUES (?, ?, ?)`,
+				change.key.kind, change.key.key, string(change.after))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write database: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+	return nil
+}
+
+func (b *SQLiteStore) incremental() bool { return true }
+
+func (b *SQLiteStore) closeBackend() error { return b.db.Close() }
+
+// Encrypt refuses: only the JSON backend can be encrypted
+func (b *SQLiteStore) Encrypt(passphrase string) error {
+	return fmt.Errorf("encryption is only supported by the %s backend", BackendJSON)
+}
+
+// Decrypt refuses: an SQLite ledger is never encrypted
+func (b *SQLiteStore) Decrypt() error {
+	return fmt.Errorf("the %s backend is not encrypted", BackendSQLite)
+}
+
+// loadHistory reads the undo history, skipping entries written for another
+// schema version since their entities can't be applied anymore
+func (b *SQLiteStore) loadHistory() (*history, error) {
+	rows, err := b.db.Query(`SELECT stack, entry FROM history WHERE schema_version = ? ORDER BY seq`, CurrentSchemaVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer rows.Close()
+
+	h := &history{SchemaVersion: CurrentSchemaVersion}
+	for rows.Next() {
+		var stack, data string
+		if err := rows.Scan(&stack, &data); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		var entry historyEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse history: %w", err)
+		}
+		if stack == "redo" {
+			h.Redo = append(h.Redo, entry)
+		} else {
+			h.Undo = append(h.Undo, entry)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return h, nil
+}
+
+// writeHistory replaces the undo history
+func (b *SQLiteStore) writeHistory(h *history) error {
+	trimHistory(h)
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM history`); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	for _, stack := range []struct {
+		name    string
+		entries []historyEntry
+	}{{"undo", h.Undo}, {"redo", h.Redo}} {
+		for _, entry := range stack.entries {
+			if err := insertHistory(tx, stack.name, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// pushHistory adds entry to the undo stack and drops the redo stack,
+// touching only the rows involved
+func (b *SQLiteStore) pushHistory(entry historyEntry) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM history WHERE stack = 'redo'`); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := insertHistory(tx, "undo", entry); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM history WHERE seq <= (
+		SELECT seq FROM history WHERE stack = 'undo' ORDER BY seq DESC LIMIT 1 OFFSET ?
+	)`, maxHistory); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// insertHistory appends entry to stack
+func insertHistory(tx *sql.Tx, stack string, entry historyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO history (stack, schema_version, entry) VALUES (?, ?, ?)`,
+		stack, CurrentSchemaVersion, string(data)); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// importHistoryFile moves a history.json left by an older version, which
+// kept the undo history of SQLite ledgers there too, into the database
+func (b *SQLiteStore) importHistoryFile() error {
+	if !fileExists(b.historyPath()) {
+		return nil
+	}
+	var rows int
+	if err := b.db.QueryRow(`SELECT COUNT(*) FROM history`).Scan(&rows); err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if rows == 0 {
+		h, err := readHistoryFile(b.historyPath())
+		if err != nil {
+			return err
+		}
+		if err := b.writeHistory(h); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(b.historyPath()); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", historyFileName, err)
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/vasylcode/wago/internal/model"
@@ -12,81 +14,191 @@ import (
 
 const dataFileName = "wago.json"
 
-// Storage handles the persistence of data in a single JSON file
+// Storage holds the ledger in memory. It implements the parts of Store
+// that JSONStore and SQLiteStore share; they embed it and persist the
+// entities it marks as changed.
 type Storage struct {
-	dataDir  string
-	dataFile string
-	data     *model.Data
-	txIndex  map[string]bool // Track tx IDs to prevent duplicates
+	dataDir   string
+	backend   backend
+	data      *model.Data
+	persisted map[entityKey][]byte // Entities as last read or written
+	dirty     map[entityKey]bool   // Entities changed since the last save
+	dirtyAll  bool                 // The whole ledger may have changed
+	txIndex   map[string]bool      // Track tx IDs to prevent duplicates
+	lock      *fileLock
+	key       *ledgerKey  // Set when the ledger is encrypted at rest
+	batch     bool        // Saves are deferred until Commit
+	unsettled []*model.Tx // Transactions changed in the batch, see settle
 }
 
-// New creates a new Storage instance
-func New() (*Storage, error) {
-	homeDir, err := os.UserHomeDir()
+// New opens the ledger of the configured profile with the store whose
+// data file exists, a JSONStore for new ledgers. It takes the data
+// directory lock, waiting for other wago processes to finish; call Close
+// to release it.
+func New() (Store, error) {
+	dataDir, err := profileDataDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+		return nil, err
 	}
 
-	dataDir := filepath.Join(homeDir, ".wago")
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	lock, err := acquireLock(filepath.Join(dataDir, lockFileName))
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Storage{
-		dataDir:  dataDir,
-		dataFile: filepath.Join(dataDir, dataFileName),
-		txIndex:  make(map[string]bool),
+		dataDir: dataDir,
+		dirty:   make(map[entityKey]bool),
+		txIndex: make(map[string]bool),
+		lock:    lock,
+	}
+
+	b, err := openBackend(s)
+	if err != nil {
+		lock.release()
+		return nil, err
 	}
 
 	if err := s.load(); err != nil {
+		b.closeBackend()
+		lock.release()
 		return nil, err
 	}
 
-	return s, nil
+	return b, nil
 }
 
-// load loads data from wago.json
+// Close releases the backend and the data directory lock. Data already
+// loaded stays readable, but further changes can no longer be saved.
+func (s *Storage) Close() error {
+	if s.lock == nil {
+		return nil
+	}
+	closeErr := s.backend.closeBackend()
+	err := s.lock.release()
+	s.lock = nil
+	if closeErr != nil {
+		return closeErr
+	}
+	return err
+}
+
+// load reads the ledger, migrating data written by older versions
 func (s *Storage) load() error {
-	// Initialize empty data structure with default prices
-	s.data = &model.Data{
-		Wallets:      make(map[string]*model.Wallet),
-		Categories:   make(map[string]*model.Category),
-		Contacts:     make(map[string]*model.Contact),
-		Transactions: make(map[string]*model.Tx),
+	s.data = newData()
+	s.persisted = make(map[entityKey][]byte)
+
+	raw, err := s.backend.read()
+	if err != nil {
+		return fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	if raw != nil {
+		plain := raw
+		if isEncrypted(raw) {
+			plain, s.key, err = openEnvelope(raw, options.KeyFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		if s.persisted, err = splitDoc(plain); err != nil {
+			return err
+		}
+
+		data, version, err := decodeData(plain)
+		if err != nil {
+			return err
+		}
+		s.data = data
+
+		// Balances summed with floats carry rounding noise
+		if version < 2 {
+			s.snapBalances()
+		}
+
+		// Keep the original data around, then write it back upgraded
+		if version < CurrentSchemaVersion {
+			if err := s.writeMigrationBackup(raw, version); err != nil {
+				return err
+			}
+			s.touchAll()
+			if err := s.persist(false); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Build transaction index for deduplication
+	s.buildTxIndex()
+
+	return nil
+}
+
+// newData returns an empty data structure with default prices
+func newData() *model.Data {
+	return &model.Data{
+		SchemaVersion: CurrentSchemaVersion,
+		Wallets:       make(map[string]*model.Wallet),
+		Categories:    make(map[string]*model.Category),
+		Contacts:      make(map[string]*model.Contact),
+		Transactions:  make(map[string]*model.Tx),
+		Schedules:     make(map[string]*model.Schedule),
 		Prices: map[string]float64{
 			"usdc": 1.0,
 			"usdt": 1.0,
 		},
+		PriceUpdated: make(map[string]time.Time),
 	}
+}
 
-	// Try to load existing wago.json
-	if data, err := os.ReadFile(s.dataFile); err == nil && len(data) > 0 {
-		if err := json.Unmarshal(data, s.data); err != nil {
-			return fmt.Errorf("failed to parse data file: %w", err)
-		}
+// decodeData parses a wago.json document, upgrading it to the current
+// schema. It also returns the schema version found in raw.
+func decodeData(raw []byte) (*model.Data, int, error) {
+	version, err := schemaVersion(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	migrated, err := migrate(raw, version)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := newData()
+	if err := json.Unmarshal(migrated, data); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse data file: %w", err)
 	}
+	data.SchemaVersion = CurrentSchemaVersion
 
 	// Ensure maps are initialized
-	if s.data.Wallets == nil {
-		s.data.Wallets = make(map[string]*model.Wallet)
+	if data.Wallets == nil {
+		data.Wallets = make(map[string]*model.Wallet)
 	}
-	if s.data.Categories == nil {
-		s.data.Categories = make(map[string]*model.Category)
+	if data.Categories == nil {
+		data.Categories = make(map[string]*model.Category)
 	}
-	if s.data.Contacts == nil {
-		s.data.Contacts = make(map[string]*model.Contact)
+	if data.Contacts == nil {
+		data.Contacts = make(map[string]*model.Contact)
 	}
-	if s.data.Transactions == nil {
-		s.data.Transactions = make(map[string]*model.Tx)
+	if data.Transactions == nil {
+		data.Transactions = make(map[string]*model.Tx)
 	}
-	if s.data.Prices == nil {
-		s.data.Prices = map[string]float64{"usdc": 1.0, "usdt": 1.0}
+	if data.Schedules == nil {
+		data.Schedules = make(map[string]*model.Schedule)
+	}
+	if data.Prices == nil {
+		data.Prices = map[string]float64{"usdc": 1.0, "usdt": 1.0}
+	}
+	if data.PriceUpdated == nil {
+		data.PriceUpdated = make(map[string]time.Time)
 	}
 
-	// Build transaction index for deduplication
-	s.buildTxIndex()
-
-	return nil
+	return data, version, nil
 }
 
 // buildTxIndex builds an index of all transaction IDs for deduplication
@@ -97,20 +209,178 @@ func (s *Storage) buildTxIndex() {
 	}
 }
 
-// save writes all data to wago.json
+// save persists all data through the backend and records the change in
+// the undo history. Inside a batch it does nothing until Commit.
 func (s *Storage) save() error {
-	data, err := json.MarshalIndent(s.data, "", "  ")
+	if s.batch {
+		return nil
+	}
+	return s.persist(true)
+}
+
+// persist writes the changed entities through the backend, optionally
+// recording them in the undo history
+func (s *Storage) persist(record bool) error {
+	if s.lock == nil {
+		return fmt.Errorf("storage is closed")
+	}
+
+	// A new ledger is written whole, defaults included
+	first := len(s.persisted) == 0
+	if first {
+		s.touchAll()
+	}
+	changes, err := s.pendingChanges()
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return err
 	}
 
-	if err := os.WriteFile(s.dataFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write data file: %w", err)
+	// Snapshot the previous version so mistakes can be rolled back
+	if err := s.writeBackup(); err != nil {
+		return err
 	}
 
+	if err := s.backend.write(changes); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.after == nil {
+			delete(s.persisted, change.key)
+		} else {
+			s.persisted[change.key] = change.after
+		}
+	}
+	s.dirty = make(map[entityKey]bool)
+	s.dirtyAll = false
+
+	if !record {
+		return nil
+	}
+	// Undoing the first save of a new ledger leads back to an empty
+	// ledger with default settings, not to nothing at all
+	if first {
+		if changes, err = diffFromEmpty(s.persisted); err != nil {
+			return err
+		}
+	}
+	if len(changes) > 0 {
+		return s.recordHistory(changes)
+	}
 	return nil
 }
 
+// touch marks records of kind as changed, so the next save compares and
+// writes them
+func (s *Storage) touch(kind string, keys ...string) {
+	for _, key := range keys {
+		s.dirty[entityKey{kind, key}] = true
+	}
+}
+
+// touchAll marks the whole ledger as changed, e.g. after replacing it
+func (s *Storage) touchAll() {
+	s.dirtyAll = true
+}
+
+// pendingChanges lists the records that differ from what was last saved.
+// Only the records marked as changed are encoded, so the cost of a save
+// follows the size of the change, not of the ledger.
+func (s *Storage) pendingChanges() ([]entityChange, error) {
+	if s.dirtyAll {
+		doc, err := json.Marshal(s.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal data: %w", err)
+		}
+		entities, err := splitDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		return diffEntities(s.persisted, entities), nil
+	}
+
+	var changes []entityChange
+	for k := range s.dirty {
+		after, err := s.entity(k)
+		if err != nil {
+			return nil, err
+		}
+		if before := s.persisted[k]; !bytes.Equal(before, after) {
+			changes = append(changes, entityChange{key: k, before: before, after: after})
+		}
+	}
+	sortChanges(changes)
+	return changes, nil
+}
+
+// entity encodes one record of the ledger as compact JSON, or returns nil
+// if it doesn't exist
+func (s *Storage) entity(k entityKey) ([]byte, error) {
+	var value interface{}
+	exists := false
+	switch k.kind {
+	case kindWallets:
+		value, exists = s.data.Wallets[k.key]
+	case kindCategories:
+		value, exists = s.data.Categories[k.key]
+	case kindContacts:
+		value, exists = s.data.Contacts[k.key]
+	case kindTransactions:
+		value, exists = s.data.Transactions[k.key]
+	case kindSchedules:
+		value, exists = s.data.Schedules[k.key]
+	case kindPrices:
+		value, exists = s.data.Prices[k.key]
+	case kindPriceUpdated:
+		value, exists = s.data.PriceUpdated[k.key]
+	default:
+		return nil, fmt.Errorf("unknown entity kind '%s'", k.kind)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	return data, nil
+}
+
+// diffFromEmpty lists the entities that differ from a new, empty ledger
+func diffFromEmpty(entities map[entityKey][]byte) ([]entityChange, error) {
+	doc, err := json.Marshal(newData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	empty, err := splitDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	return diffEntities(empty, entities), nil
+}
+
+// renderDoc formats a compact document as it is stored in wago.json,
+// encrypting it when the ledger is encrypted
+func (s *Storage) renderDoc(doc []byte) ([]byte, os.FileMode, error) {
+	var data bytes.Buffer
+	if err := json.Indent(&data, doc, "", "  "); err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	if s.key == nil {
+		return data.Bytes(), 0644, nil
+	}
+	sealed, err := s.key.seal(data.Bytes())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	return sealed, secretFileMode, nil
+}
+
+// IsEncrypted reports whether the ledger is encrypted at rest
+func (s *Storage) IsEncrypted() bool {
+	return s.key != nil
+}
+
 // GetPrices returns the price map
 func (s *Storage) GetPrices() map[string]float64 {
 	return s.data.Prices
@@ -119,13 +389,20 @@ func (s *Storage) GetPrices() map[string]float64 {
 // SetPrice sets a coin price
 func (s *Storage) SetPrice(coin string, price float64) error {
 	s.data.Prices[coin] = price
+	s.data.PriceUpdated[coin] = time.Now().UTC()
+	s.touch(kindPrices, coin)
+	s.touch(kindPriceUpdated, coin)
 	return s.save()
 }
 
 // SetPrices updates multiple coin prices in one save.
 func (s *Storage) SetPrices(prices map[string]float64) error {
+	now := time.Now().UTC()
 	for coin, price := range prices {
 		s.data.Prices[coin] = price
+		s.data.PriceUpdated[coin] = now
+		s.touch(kindPrices, coin)
+		s.touch(kindPriceUpdated, coin)
 	}
 	return s.save()
 }
@@ -137,6 +414,7 @@ func (s *Storage) AddWallet(wallet *model.Wallet) error {
 	}
 
 	s.data.Wallets[wallet.Name] = wallet
+	s.touch(kindWallets, wallet.Name)
 	return s.save()
 }
 
@@ -155,22 +433,79 @@ func (s *Storage) UpdateWallet(name string, wallet *model.Wallet) error {
 		return fmt.Errorf("wallet with name '%s' not found", name)
 	}
 
-	// If the name is changing, delete the old entry
+	// If the name is changing, delete the old entry and move its transactions
 	if name != wallet.Name {
+		if err := s.checkNewName(name, wallet.Name); err != nil {
+			return err
+		}
 		delete(s.data.Wallets, name)
+		s.renameTxRefs(name, wallet.Name)
 	}
 
 	s.data.Wallets[wallet.Name] = wallet
+	s.touch(kindWallets, name, wallet.Name)
 	return s.save()
 }
 
-// DeleteWallet deletes a wallet
-func (s *Storage) DeleteWallet(name string) error {
-	if _, exists := s.data.Wallets[name]; !exists {
+// WalletDeletePolicy decides what happens to the transactions of a
+// deleted wallet
+type WalletDeletePolicy int
+
+const (
+	// DeleteRefuse refuses to delete a wallet that has transactions
+	DeleteRefuse WalletDeletePolicy = iota
+	// DeleteCascade deletes the wallet's transactions and schedules too and
+	// replays the balances of the counterpart wallets
+	DeleteCascade
+	// DeleteArchive keeps the wallet and its history but hides it
+	DeleteArchive
+)
+
+// DeleteWallet deletes a wallet, handling its transactions per policy
+func (s *Storage) DeleteWallet(name string, policy WalletDeletePolicy) error {
+	_, exists := s.data.Wallets[name]
+	if !exists {
 		return fmt.Errorf("wallet with name '%s' not found", name)
 	}
 
-	delete(s.data.Wallets, name)
+	txs := s.GetWalletTransactions(name)
+	var schedules []string
+	for _, sch := range s.data.Schedules {
+		if scheduleUses(sch, name) {
+			schedules = append(schedules, sch.Name)
+		}
+	}
+	switch policy {
+	case DeleteArchive:
+		return s.SetWalletArchived(name, true)
+
+	case DeleteCascade:
+		var counterparts []string
+		for _, tx := range txs {
+			delete(s.data.Transactions, tx.ID)
+			delete(s.txIndex, tx.ID)
+			s.touch(kindTransactions, tx.ID)
+			counterparts = append(counterparts, txWallets(tx)...)
+		}
+		for _, sch := range schedules {
+			delete(s.data.Schedules, sch)
+		}
+		s.touch(kindSchedules, schedules...)
+		delete(s.data.Wallets, name)
+		s.touch(kindWallets, name)
+		s.refreshBalances(counterparts...)
+
+	default:
+		if len(txs) > 0 {
+			return fmt.Errorf("wallet '%s' has %d transaction(s); cascade to delete them too or archive the wallet instead", name, len(txs))
+		}
+		if len(schedules) > 0 {
+			return fmt.Errorf("wallet '%s' is used by %d schedule(s); cascade to delete them too or archive the wallet instead", name, len(schedules))
+		}
+		delete(s.data.Wallets, name)
+		s.touch(kindWallets, name)
+	}
+
 	return s.save()
 }
 
@@ -190,6 +525,7 @@ func (s *Storage) AddCategory(category *model.Category) error {
 	}
 
 	s.data.Categories[category.Name] = category
+	s.touch(kindCategories, category.Name)
 	return s.save()
 }
 
@@ -209,11 +545,13 @@ func (s *Storage) DeleteCategory(name string) error {
 	}
 
 	delete(s.data.Categories, name)
+	s.touch(kindCategories, name)
 
 	// Update wallets that use this category
 	for _, wallet := range s.data.Wallets {
 		if wallet.Category == name {
 			wallet.Category = ""
+			s.touch(kindWallets, wallet.Name)
 		}
 	}
 
@@ -236,6 +574,7 @@ func (s *Storage) AddContact(contact *model.Contact) error {
 	}
 
 	s.data.Contacts[contact.Name] = contact
+	s.touch(kindContacts, contact.Name)
 	return s.save()
 }
 
@@ -255,6 +594,7 @@ func (s *Storage) DeleteContact(name string) error {
 	}
 
 	delete(s.data.Contacts, name)
+	s.touch(kindContacts, name)
 	return s.save()
 }
 
@@ -267,105 +607,228 @@ func (s *Storage) ListContacts() []*model.Contact {
 	return contacts
 }
 
-// AddTransaction adds a transaction and updates wallet balances
+// AddTransaction adds a transaction to the journal and replays the balances it touches
 func (s *Storage) AddTransaction(tx *model.Tx) error {
 	// Check for duplicate
 	if tx.ID != "" && s.txIndex[tx.ID] {
-		return fmt.Errorf("transaction with ID '%s' already exists", tx.ID)
+		return fmt.Errorf("transaction with ID '%s' %w", tx.ID, ErrTxExists)
+	}
+	if err := s.validateTx(tx); err != nil {
+		return err
+	}
+	if tx.Type == model.TxTypeLPAdd && tx.CostBasis.IsZero() {
+		tx.CostBasis = s.depositValue(tx)
+	}
+
+	// Store transaction in global map
+	s.data.Transactions[tx.ID] = tx
+	if err := s.settle(tx); err != nil {
+		delete(s.data.Transactions, tx.ID)
+		return err
+	}
+	s.txIndex[tx.ID] = true
+	s.touch(kindTransactions, tx.ID)
+
+	return s.save()
+}
+
+// UpdateTransaction replaces the transaction with tx's ID, keeping the ID.
+// Balances of the wallets touched before and after are replayed, so the
+// old effect is reversed and the new one applied in one save.
+func (s *Storage) UpdateTransaction(tx *model.Tx) error {
+	old, exists := s.data.Transactions[tx.ID]
+	if !exists {
+		return fmt.Errorf("transaction with ID '%s' not found", tx.ID)
+	}
+	if err := s.validateTx(tx); err != nil {
+		return err
+	}
+
+	s.data.Transactions[tx.ID] = tx
+	if err := s.settle(old, tx); err != nil {
+		s.data.Transactions[tx.ID] = old
+		return err
+	}
+	s.touch(kindTransactions, tx.ID)
+
+	return s.save()
+}
+
+// validateTx checks that the wallets a transaction touches exist and that
+// it moves positive amounts and pays no negative fee
+func (s *Storage) validateTx(tx *model.Tx) error {
+	tags, err := NormalizeTags(tx.Tags)
+	if err != nil {
+		return err
 	}
+	tx.Tags = tags
+	NormalizeCoins(tx)
 
-	// Validate and update balances based on tx type
 	switch tx.Type {
 	case model.TxTypeDeposit:
-		wallet, err := s.GetWallet(tx.ToWallet)
-		if err != nil {
+		if _, err := s.GetWallet(tx.ToWallet); err != nil {
+			return err
+		}
+		if err := validateSingleCoin(tx); err != nil {
 			return err
 		}
-		s.updateBalance(wallet, tx.Coin, tx.Amount)
 
 	case model.TxTypeWithdraw:
-		wallet, err := s.GetWallet(tx.FromWallet)
-		if err != nil {
+		if _, err := s.GetWallet(tx.FromWallet); err != nil {
+			return err
+		}
+		if err := validateSingleCoin(tx); err != nil {
 			return err
 		}
-		s.updateBalance(wallet, tx.Coin, -tx.Amount)
 
 	case model.TxTypeTransfer:
-		var fromWallet, toWallet *model.Wallet
-		var fromErr, toErr error
+		_, fromErr := s.GetWallet(tx.FromWallet)
+		_, toErr := s.GetWallet(tx.ToWallet)
+		if fromErr != nil && toErr != nil {
+			return fmt.Errorf("both source and destination wallets are invalid")
+		}
+		if err := validateSingleCoin(tx); err != nil {
+			return err
+		}
 
-		if tx.FromWallet != "" {
-			fromWallet, fromErr = s.GetWallet(tx.FromWallet)
+	case model.TxTypeSwap:
+		if _, err := s.GetWallet(tx.SwapWallet); err != nil {
+			return err
+		}
+		if tx.SellCoin == "" || tx.BuyCoin == "" {
+			return fmt.Errorf("swap transaction needs both coins")
 		}
-		if tx.ToWallet != "" {
-			toWallet, toErr = s.GetWallet(tx.ToWallet)
+		if !tx.SellAmount.IsPositive() || !tx.BuyAmount.IsPositive() {
+			return fmt.Errorf("swap amounts must be positive")
 		}
 
-		if fromErr != nil && toErr != nil {
-			return fmt.Errorf("both source and destination wallets are invalid")
+	case model.TxTypeMulti:
+		if err := s.validateLegs(tx); err != nil {
+			return err
 		}
 
-		if fromWallet != nil {
-			s.updateBalance(fromWallet, tx.Coin, -tx.Amount)
+	case model.TxTypeLPAdd, model.TxTypeLPRemove:
+		if err := s.validateLP(tx); err != nil {
+			return err
 		}
-		if toWallet != nil {
-			s.updateBalance(toWallet, tx.Coin, tx.Amount-tx.Fee)
+
+	case model.TxTypeStake, model.TxTypeUnstake:
+		if _, err := s.GetWallet(tx.FromWallet); err != nil {
+			return err
+		}
+		if err := validateSingleCoin(tx); err != nil {
+			return err
 		}
 
-	case model.TxTypeSwap:
-		wallet, err := s.GetWallet(tx.SwapWallet)
-		if err != nil {
+	case model.TxTypeBridge:
+		// Both ends are own wallets, on different chains
+		if _, err := s.GetWallet(tx.FromWallet); err != nil {
 			return err
 		}
-		s.updateBalance(wallet, tx.SellCoin, -tx.SellAmount)
-		s.updateBalance(wallet, tx.BuyCoin, tx.BuyAmount)
-	}
+		if _, err := s.GetWallet(tx.ToWallet); err != nil {
+			return err
+		}
+		if err := validateSingleCoin(tx); err != nil {
+			return err
+		}
+		if !tx.ReceivedAmount.IsPositive() {
+			return fmt.Errorf("bridge received amount must be positive")
+		}
+		if tx.FromChain != "" && strings.EqualFold(tx.FromChain, tx.ToChain) {
+			return fmt.Errorf("bridge source and destination chain are both %s", tx.FromChain)
+		}
 
-	// Store transaction in global map
-	s.data.Transactions[tx.ID] = tx
-	s.txIndex[tx.ID] = true
+	case model.TxTypeReward, model.TxTypeAirdrop, model.TxTypeIncome:
+		if _, err := s.GetWallet(tx.ToWallet); err != nil {
+			return err
+		}
+		if err := validateSingleCoin(tx); err != nil {
+			return err
+		}
 
-	return s.save()
-}
+	default:
+		return fmt.Errorf("unknown transaction type '%s'", tx.Type)
+	}
 
-// DeleteTransaction deletes a transaction and reverses balance changes
-func (s *Storage) DeleteTransaction(txID string) error {
-	tx, exists := s.data.Transactions[txID]
-	if !exists {
-		return fmt.Errorf("transaction with ID '%s' not found", txID)
+	if tx.Fee.IsNegative() {
+		return fmt.Errorf("fee must not be negative")
 	}
 
-	// Reverse balance changes
-	switch tx.Type {
-	case model.TxTypeDeposit:
-		if wallet, err := s.GetWallet(tx.ToWallet); err == nil {
-			s.updateBalance(wallet, tx.Coin, -tx.Amount)
+	// The fee may be paid by any wallet, or by a party of the transaction
+	if tx.FeeWallet != "" && tx.FeeWallet != tx.FromWallet && tx.FeeWallet != tx.ToWallet {
+		if _, err := s.GetWallet(tx.FeeWallet); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-	case model.TxTypeWithdraw:
-		if wallet, err := s.GetWallet(tx.FromWallet); err == nil {
-			s.updateBalance(wallet, tx.Coin, tx.Amount)
+// validateLegs checks that every leg of a multi-leg transaction moves a
+// coin of a wallet or contact, and that at least one leg is on a wallet
+func (s *Storage) validateLegs(tx *model.Tx) error {
+	if len(tx.Legs) == 0 {
+		return fmt.Errorf("multi-leg transaction needs at least one leg")
+	}
+	owned := false
+	for i, leg := range tx.Legs {
+		if _, err := s.GetWallet(leg.Wallet); err == nil {
+			owned = true
+		} else if _, err := s.GetContact(leg.Wallet); err != nil {
+			return fmt.Errorf("leg %d: wallet or contact '%s' not found", i+1, leg.Wallet)
 		}
-
-	case model.TxTypeTransfer:
-		if wallet, err := s.GetWallet(tx.FromWallet); err == nil {
-			s.updateBalance(wallet, tx.Coin, tx.Amount)
+		if leg.Coin == "" {
+			return fmt.Errorf("leg %d needs a coin", i+1)
 		}
-		if wallet, err := s.GetWallet(tx.ToWallet); err == nil {
-			// Reverse: add back the received amount (which was amount minus fee)
-			s.updateBalance(wallet, tx.Coin, -(tx.Amount - tx.Fee))
+		if leg.Amount.IsZero() {
+			return fmt.Errorf("leg %d moves nothing", i+1)
 		}
+	}
+	if !owned {
+		return fmt.Errorf("multi-leg transaction must move funds of at least one wallet")
+	}
+	return nil
+}
 
-	case model.TxTypeSwap:
-		if wallet, err := s.GetWallet(tx.SwapWallet); err == nil {
-			s.updateBalance(wallet, tx.SellCoin, tx.SellAmount)
-			s.updateBalance(wallet, tx.BuyCoin, -tx.BuyAmount)
-		}
+// validateSingleCoin checks that tx moves a positive amount of a coin
+func validateSingleCoin(tx *model.Tx) error {
+	if tx.Coin == "" {
+		return fmt.Errorf("%s transaction needs a coin", tx.Type)
+	}
+	if !tx.Amount.IsPositive() {
+		return fmt.Errorf("%s amount must be positive", tx.Type)
+	}
+	return nil
+}
+
+// NormalizeCoins uppercases every coin symbol of tx, so a coin has one
+// balance however it was spelled
+func NormalizeCoins(tx *model.Tx) {
+	tx.Coin = strings.ToUpper(tx.Coin)
+	tx.SellCoin = strings.ToUpper(tx.SellCoin)
+	tx.BuyCoin = strings.ToUpper(tx.BuyCoin)
+	tx.FeeCoin = strings.ToUpper(tx.FeeCoin)
+	tx.ReceivedCoin = strings.ToUpper(tx.ReceivedCoin)
+	tx.PairCoin = strings.ToUpper(tx.PairCoin)
+	for i := range tx.Legs {
+		tx.Legs[i].Coin = strings.ToUpper(tx.Legs[i].Coin)
+	}
+}
+
+// DeleteTransaction deletes a transaction and replays the balances it touched
+func (s *Storage) DeleteTransaction(txID string) error {
+	tx, exists := s.data.Transactions[txID]
+	if !exists {
+		return fmt.Errorf("transaction with ID '%s' not found", txID)
 	}
 
 	// Remove from storage
 	delete(s.data.Transactions, txID)
+	if err := s.settle(tx); err != nil {
+		s.data.Transactions[txID] = tx
+		return err
+	}
 	delete(s.txIndex, txID)
+	s.touch(kindTransactions, txID)
 
 	return s.save()
 }
@@ -392,32 +855,21 @@ func (s *Storage) ListTransactions() []*model.Tx {
 func (s *Storage) GetWalletTransactions(walletName string) []*model.Tx {
 	var txs []*model.Tx
 	for _, tx := range s.data.Transactions {
-		if tx.FromWallet == walletName || tx.ToWallet == walletName || tx.SwapWallet == walletName {
+		if tx.FromWallet == walletName || tx.ToWallet == walletName || tx.SwapWallet == walletName || tx.FeeWallet == walletName || hasLeg(tx, walletName) {
 			txs = append(txs, tx)
 		}
 	}
 	return txs
 }
 
-// updateBalance updates a wallet's balance for a specific coin
-func (s *Storage) updateBalance(wallet *model.Wallet, coin string, amount float64) {
-	if wallet.Balances == nil {
-		wallet.Balances = []*model.Balance{}
-	}
-
-	// Find existing balance for this coin
-	for _, balance := range wallet.Balances {
-		if balance.Coin == coin {
-			balance.Amount += amount
-			return
+// hasLeg reports whether a leg of tx moves funds of name
+func hasLeg(tx *model.Tx, name string) bool {
+	for _, leg := range tx.Legs {
+		if leg.Wallet == name {
+			return true
 		}
 	}
-
-	// If no existing balance, create a new one
-	wallet.Balances = append(wallet.Balances, &model.Balance{
-		Coin:   coin,
-		Amount: amount,
-	})
+	return false
 }
 
 // GenerateTxID generates a unique transaction ID
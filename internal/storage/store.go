@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// Store is the ledger API used by the commands and the dashboard. It is
+// implemented by JSONStore and SQLiteStore.
+type Store interface {
+	Close() error
+	Backend() string
+	MigrateBackend(kind string) error
+
+	// Batches
+	Begin() error
+	Commit() error
+	Rollback() error
+	Update(fn func(tx Store) error) error
+
+	// Encryption
+	IsEncrypted() bool
+	Encrypt(passphrase string) error
+	Decrypt() error
+
+	// History
+	Undo() (string, error)
+	Redo() (string, error)
+
+	// Sync
+	Sync(path string, resolve SyncResolver) (*SyncResult, error)
+
+	// Backups
+	ListBackups() ([]Backup, error)
+	LoadBackup(id string) (*model.Data, error)
+	RestoreBackup(id string) error
+	DiffBackup(id string) (*DataDiff, error)
+
+	// Prices
+	GetPrices() map[string]float64
+	SetPrice(coin string, price float64) error
+	SetPrices(prices map[string]float64) error
+
+	// Wallets
+	AddWallet(wallet *model.Wallet) error
+	GetWallet(name string) (*model.Wallet, error)
+	UpdateWallet(name string, wallet *model.Wallet) error
+	DeleteWallet(name string, policy WalletDeletePolicy) error
+	RenameWallet(oldName, newName string) error
+	SetWalletArchived(name string, archived bool) error
+	ListWallets() []*model.Wallet
+
+	// Categories
+	AddCategory(category *model.Category) error
+	GetCategory(name string) (*model.Category, error)
+	DeleteCategory(name string) error
+	RenameCategory(oldName, newName string) error
+	SetCategoryArchived(name string, archived bool) error
+	ListCategories() []*model.Category
+
+	// Contacts
+	AddContact(contact *model.Contact) error
+	GetContact(name string) (*model.Contact, error)
+	DeleteContact(name string) error
+	RenameContact(oldName, newName string) error
+	SetContactArchived(name string, archived bool) error
+	ListContacts() []*model.Contact
+
+	// Transactions
+	AddTransaction(tx *model.Tx) error
+	UpdateTransaction(tx *model.Tx) error
+	DeleteTransaction(txID string) error
+	GetTransaction(txID string) (*model.Tx, error)
+	ListTransactions() []*model.Tx
+	GetWalletTransactions(walletName string) []*model.Tx
+	ListPositions(wallet string) []*model.Position
+	BalanceAt(wallet, coin string, at time.Time) decimal.Decimal
+	GenerateTxID() string
+	Rebuild(dryRun bool) ([]BalanceChange, error)
+	Doctor(fix bool) ([]Issue, error)
+
+	// Schedules
+	AddSchedule(sch *model.Schedule) error
+	GetSchedule(name string) (*model.Schedule, error)
+	DeleteSchedule(name string) error
+	ListSchedules() []*model.Schedule
+	RunSchedules(now time.Time) ([]*model.Tx, error)
+}
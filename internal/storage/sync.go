@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// syncDirName holds the common ancestor of each synced ledger
+const syncDirName = "sync"
+
+// SyncChoice picks a side of a conflicting record
+type SyncChoice int
+
+const (
+	// SyncOurs keeps this ledger's version
+	SyncOurs SyncChoice = iota
+	// SyncTheirs takes the other ledger's version
+	SyncTheirs
+)
+
+// SyncConflict is a record changed differently on both sides since the
+// last sync. Ours and Theirs hold its JSON, or "" when it was deleted.
+type SyncConflict struct {
+	Kind   string
+	Key    string
+	Ours   string
+	Theirs string
+}
+
+// SyncResolver decides a conflict, e.g. by asking the user
+type SyncResolver func(conflict SyncConflict) (SyncChoice, error)
+
+// SyncResult summarizes a sync
+type SyncResult struct {
+	Pulled    int // records taken from the other ledger
+	Pushed    int // records the other ledger received from us
+	Conflicts int
+	Balances  []BalanceChange
+}
+
+// Sync three-way merges the ledger with the wago.json at path, using the
+// state of the last sync with that file as the common ancestor. Wallets,
+// categories and contacts merge by name, transactions by ID and prices by
+// the newest timestamp; anything else changed on both sides goes to
+// resolve. The merged ledger is saved here as one undoable change, written
+// back to path and remembered as the next ancestor. Balances are replayed
+// from the merged transactions.
+func (s *Storage) Sync(path string, resolve SyncResolver) (*SyncResult, error) {
+	if s.batch {
+		return nil, errBatchInProgress
+	}
+	if s.lock == nil {
+		return nil, fmt.Errorf("storage is closed")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if path == s.backend.path() {
+		return nil, fmt.Errorf("cannot sync the ledger with itself")
+	}
+
+	doc, err := json.Marshal(s.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	ours, err := splitSyncDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := readSyncDoc(path)
+	if err != nil {
+		return nil, err
+	}
+	base, err := readSyncDoc(s.syncBasePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync base: %w", err)
+	}
+
+	merged, result, err := mergeEntities(base, ours, theirs, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	// Adopt the merge and replay balances, which are left out of it
+	doc, err = assembleDoc(merged)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := decodeData(doc)
+	if err != nil {
+		return nil, err
+	}
+	restoreBalances(data, s.data)
+	s.data = data
+	s.buildTxIndex()
+	result.Balances = s.refreshBalances(s.walletNames()...)
+	s.touchAll()
+	if err := s.persist(true); err != nil {
+		return nil, err
+	}
+
+	// Hand the result to the other side and remember it as the ancestor
+	if doc, err = json.Marshal(s.data); err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	rendered, perm, err := s.renderDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, rendered, perm); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.syncBasePath(path)), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create sync directory: %w", err)
+	}
+	if err := writeFileAtomic(s.syncBasePath(path), rendered, perm); err != nil {
+		return nil, fmt.Errorf("failed to write sync base: %w", err)
+	}
+	return result, nil
+}
+
+// syncBasePath returns where the common ancestor for path is kept
+func (s *Storage) syncBasePath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(s.dataDir, syncDirName, hex.EncodeToString(sum[:8])+".json")
+}
+
+// readSyncDoc reads a ledger for merging, decrypting and upgrading it as
+// needed. A missing file is an empty ledger.
+func readSyncDoc(path string) (map[entityKey][]byte, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[entityKey][]byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isEncrypted(raw) {
+		if raw, _, err = openEnvelope(raw, options.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	data, _, err := decodeData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	doc, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	return splitSyncDoc(doc)
+}
+
+// splitSyncDoc splits doc like splitDoc but drops wallet balances: they
+// are derived from the transactions, so both sides always disagree on
+// them when both added transactions
+func splitSyncDoc(doc []byte) (map[entityKey][]byte, error) {
+	entities, err := splitDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	for k, value := range entities {
+		if k.kind != "wallets" {
+			continue
+		}
+		var wallet map[string]json.RawMessage
+		if err := json.Unmarshal(value, &wallet); err != nil {
+			return nil, fmt.Errorf("failed to parse data: %w", err)
+		}
+		delete(wallet, "balances")
+		if entities[k], err = json.Marshal(wallet); err != nil {
+			return nil, fmt.Errorf("failed to marshal data: %w", err)
+		}
+	}
+	return entities, nil
+}
+
+// mergeEntities three-way merges ours and theirs against base. A record
+// changed on one side only takes that change; prices changed on both
+// sides keep the newer one; other records changed on both sides differently
+// are resolved by resolve.
+func mergeEntities(base, ours, theirs map[entityKey][]byte, resolve SyncResolver) (map[entityKey][]byte, *SyncResult, error) {
+	keys := make(map[entityKey]bool)
+	for _, entities := range []map[entityKey][]byte{base, ours, theirs} {
+		for k := range entities {
+			keys[k] = true
+		}
+	}
+	sorted := make([]entityKey, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].kind != sorted[j].kind {
+			return sorted[i].kind < sorted[j].kind
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	same := func(a, b []byte) bool {
+		return (a == nil) == (b == nil) && bytes.Equal(a, b)
+	}
+
+	merged := make(map[entityKey][]byte)
+	result := &SyncResult{}
+	for _, k := range sorted {
+		b, o, t := base[k], ours[k], theirs[k]
+
+		var pick []byte
+		switch {
+		case same(o, t), same(t, b):
+			pick = o
+		case same(o, b):
+			pick = t
+		case k.kind == "prices" || k.kind == "price_updated":
+			pick = o
+			if priceTime(theirs, k.key).After(priceTime(ours, k.key)) {
+				pick = t
+			}
+		default:
+			result.Conflicts++
+			choice, err := resolve(SyncConflict{Kind: singularKind(k.kind), Key: k.key, Ours: string(o), Theirs: string(t)})
+			if err != nil {
+				return nil, nil, err
+			}
+			pick = o
+			if choice == SyncTheirs {
+				pick = t
+			}
+		}
+
+		if !same(pick, o) {
+			result.Pulled++
+		}
+		if !same(pick, t) {
+			result.Pushed++
+		}
+		if pick != nil {
+			merged[k] = pick
+		}
+	}
+	return merged, result, nil
+}
+
+// priceTime returns when coin's price was last set in entities
+func priceTime(entities map[entityKey][]byte, coin string) time.Time {
+	var t time.Time
+	if raw, exists := entities[entityKey{"price_updated", coin}]; exists {
+		json.Unmarshal(raw, &t)
+	}
+	return t
+}
+
+// restoreBalances gives the merged wallets the balances cached before the
+// merge, so replaying them reports what actually changed
+func restoreBalances(merged, previous *model.Data) {
+	for name, wallet := range merged.Wallets {
+		if old, exists := previous.Wallets[name]; exists {
+			wallet.Balances = old.Balances
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+func TestMergeEntities(t *testing.T) {
+	wallet := entityKey{"wallets", "w1"}
+	price := entityKey{"prices", "eth"}
+	priceUpdated := entityKey{"price_updated", "eth"}
+
+	tests := []struct {
+		name      string
+		base      map[entityKey]string
+		ours      map[entityKey]string
+		theirs    map[entityKey]string
+		choice    SyncChoice
+		want      map[entityKey]string
+		pulled    int
+		pushed    int
+		conflicts int
+	}{
+		{
+			name:   "unchanged",
+			base:   map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:   map[entityKey]string{wallet: `{"note":"a"}`},
+			theirs: map[entityKey]string{wallet: `{"note":"a"}`},
+			want:   map[entityKey]string{wallet: `{"note":"a"}`},
+		},
+		{
+			name:   "changed here",
+			base:   map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:   map[entityKey]string{wallet: `{"note":"b"}`},
+			theirs: map[entityKey]string{wallet: `{"note":"a"}`},
+			want:   map[entityKey]string{wallet: `{"note":"b"}`},
+			pushed: 1,
+		},
+		{
+			name:   "changed there",
+			base:   map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:   map[entityKey]string{wallet: `{"note":"a"}`},
+			theirs: map[entityKey]string{wallet: `{"note":"b"}`},
+			want:   map[entityKey]string{wallet: `{"note":"b"}`},
+			pulled: 1,
+		},
+		{
+			name:   "same change on both sides",
+			base:   map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:   map[entityKey]string{wallet: `{"note":"b"}`},
+			theirs: map[entityKey]string{wallet: `{"note":"b"}`},
+			want:   map[entityKey]string{wallet: `{"note":"b"}`},
+		},
+		{
+			name:   "added there",
+			ours:   map[entityKey]string{},
+			theirs: map[entityKey]string{wallet: `{"note":"a"}`},
+			want:   map[entityKey]string{wallet: `{"note":"a"}`},
+			pulled: 1,
+		},
+		{
+			name:   "deleted there",
+			base:   map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:   map[entityKey]string{wallet: `{"note":"a"}`},
+			theirs: map[entityKey]string{},
+			want:   map[entityKey]string{},
+			pulled: 1,
+		},
+		{
+			name:      "conflict kept ours",
+			base:      map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:      map[entityKey]string{wallet: `{"note":"b"}`},
+			theirs:    map[entityKey]string{wallet: `{"note":"c"}`},
+			choice:    SyncOurs,
+			want:      map[entityKey]string{wallet: `{"note":"b"}`},
+			pushed:    1,
+			conflicts: 1,
+		},
+		{
+			name:      "conflict took theirs",
+			base:      map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:      map[entityKey]string{wallet: `{"note":"b"}`},
+			theirs:    map[entityKey]string{wallet: `{"note":"c"}`},
+			choice:    SyncTheirs,
+			want:      map[entityKey]string{wallet: `{"note":"c"}`},
+			pulled:    1,
+			conflicts: 1,
+		},
+		{
+			name:      "deleted here, changed there",
+			base:      map[entityKey]string{wallet: `{"note":"a"}`},
+			ours:      map[entityKey]string{},
+			theirs:    map[entityKey]string{wallet: `{"note":"c"}`},
+			choice:    SyncOurs,
+			want:      map[entityKey]string{},
+			pushed:    1,
+			conflicts: 1,
+		},
+		{
+			name:   "newer price wins",
+			base:   map[entityKey]string{price: `2000`, priceUpdated: `"2024-01-01T00:00:00Z"`},
+			ours:   map[entityKey]string{price: `2100`, priceUpdated: `"2024-01-02T00:00:00Z"`},
+			theirs: map[entityKey]string{price: `2200`, priceUpdated: `"2024-01-03T00:00:00Z"`},
+			want:   map[entityKey]string{price: `2200`, priceUpdated: `"2024-01-03T00:00:00Z"`},
+			pulled: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := 0
+			resolve := func(conflict SyncConflict) (SyncChoice, error) {
+				asked++
+				return tt.choice, nil
+			}
+			merged, result, err := mergeEntities(entityBytes(tt.base), entityBytes(tt.ours), entityBytes(tt.theirs), resolve)
+			if err != nil {
+				t.Fatalf("mergeEntities() error = %v", err)
+			}
+			if got := entityStrings(merged); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merged = %v, want %v", got, tt.want)
+			}
+			if result.Pulled != tt.pulled || result.Pushed != tt.pushed || result.Conflicts != tt.conflicts {
+				t.Errorf("pulled/pushed/conflicts = %d/%d/%d, want %d/%d/%d",
+					result.Pulled, result.Pushed, result.Conflicts, tt.pulled, tt.pushed, tt.conflicts)
+			}
+			if asked != tt.conflicts {
+				t.Errorf("resolver asked %d times, want %d", asked, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeEntitiesResolverError(t *testing.T) {
+	k := entityKey{"contacts", "bob"}
+	errAbort := errors.New("aborted")
+	_, _, err := mergeEntities(
+		entityBytes(map[entityKey]string{k: `{"note":"a"}`}),
+		entityBytes(map[entityKey]string{k: `{"note":"b"}`}),
+		entityBytes(map[entityKey]string{k: `{"note":"c"}`}),
+		func(SyncConflict) (SyncChoice, error) { return SyncOurs, errAbort },
+	)
+	if !errors.Is(err, errAbort) {
+		t.Errorf("mergeEntities() error = %v, want %v", err, errAbort)
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("WAGO_PROFILE", "")
+	t.Setenv("WAGO_PASSPHRASE", "")
+	t.Setenv("WAGO_KEYFILE", "")
+	t.Cleanup(func() { options = Options{} })
+
+	open := func(profile string) Store {
+		t.Helper()
+		if err := Configure(Options{DataDir: dir, Profile: profile}); err != nil {
+			t.Fatal(err)
+		}
+		s, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	balance := func(s Store, wallet, coin string) string {
+		t.Helper()
+		w, err := s.GetWallet(wallet)
+		check(err)
+		for _, bal := range w.Balances {
+			if bal.Coin == coin && !bal.Staked {
+				return bal.Amount.String()
+			}
+		}
+		return "0"
+	}
+	noConflicts := func(conflict SyncConflict) (SyncChoice, error) {
+		t.Errorf("unexpected conflict on %s %s", conflict.Kind, conflict.Key)
+		return SyncOurs, nil
+	}
+
+	// The other machine's ledger is a profile of the same data directory
+	desktop := open("desktop")
+	check(desktop.Close())
+	desktopPath := filepath.Join(dir, profilesDirName, "desktop", "wago.json")
+
+	// First sync hands everything over
+	laptop := open("")
+	check(laptop.AddWallet(&model.Wallet{Name: "w1", Address: "0x1", Chain: "eth", Type: "hot"}))
+	check(laptop.AddTransaction(&model.Tx{ID: "t1", Type: model.TxTypeDeposit, ToWallet: "w1", Coin: "ETH", Amount: dec("10"), Date: day("2024-01-01")}))
+	result, err := laptop.Sync(desktopPath, noConflicts)
+	check(err)
+	if result.Pushed == 0 || result.Pulled != 0 {
+		t.Errorf("first sync pushed/pulled = %d/%d, want some/0", result.Pushed, result.Pulled)
+	}
+	check(laptop.Close())
+
+	// Both sides add a transaction and edit the wallet note differently
+	desktop = open("desktop")
+	if got := balance(desktop, "w1", "ETH"); got != "10" {
+		t.Errorf("desktop balance after first sync = %s, want 10", got)
+	}
+	check(desktop.AddTransaction(&model.Tx{ID: "t2", Type: model.TxTypeDeposit, ToWallet: "w1", Coin: "ETH", Amount: dec("5"), Date: day("2024-02-01")}))
+	check(desktop.UpdateWallet("w1", &model.Wallet{Name: "w1", Address: "0x1", Chain: "eth", Type: "hot", Note: "desk"}))
+	check(desktop.Close())
+
+	laptop = open("")
+	check(laptop.AddTransaction(&model.Tx{ID: "t3", Type: model.TxTypeWithdraw, FromWallet: "w1", Coin: "ETH", Amount: dec("3"), Date: day("2024-03-01")}))
+	w, err := laptop.GetWallet("w1")
+	check(err)
+	check(laptop.UpdateWallet("w1", &model.Wallet{Name: "w1", Address: "0x1", Chain: "eth", Type: "hot", Note: "lap", Balances: w.Balances}))
+
+	var conflicts []SyncConflict
+	result, err = laptop.Sync(desktopPath, func(conflict SyncConflict) (SyncChoice, error) {
+		conflicts = append(conflicts, conflict)
+		return SyncTheirs, nil
+	})
+	check(err)
+	if len(conflicts) != 1 || conflicts[0].Kind != "wallet" || conflicts[0].Key != "w1" {
+		t.Errorf("conflicts = %+v, want one on wallet w1", conflicts)
+	}
+	if result.Pulled != 2 || result.Pushed != 1 {
+		t.Errorf("second sync pushed/pulled = %d/%d, want 1/2", result.Pushed, result.Pulled)
+	}
+	if got := balance(laptop, "w1", "ETH"); got != "12" {
+		t.Errorf("laptop balance after sync = %s, want 12", got)
+	}
+	check(laptop.Close())
+
+	// The other side received the merge, and a rerun has nothing to do
+	desktop = open("desktop")
+	if got := balance(desktop, "w1", "ETH"); got != "12" {
+		t.Errorf("desktop balance after sync = %s, want 12", got)
+	}
+	if w, _ := desktop.GetWallet("w1"); w == nil || w.Note != "desk" {
+		t.Errorf("desktop wallet = %+v, want note 'desk'", w)
+	}
+	if n := len(desktop.ListTransactions()); n != 3 {
+		t.Errorf("desktop has %d transactions, want 3", n)
+	}
+	check(desktop.Close())
+
+	laptop = open("")
+	result, err = laptop.Sync(desktopPath, noConflicts)
+	check(err)
+	if result.Pushed != 0 || result.Pulled != 0 {
+		t.Errorf("rerun pushed/pulled = %d/%d, want 0/0", result.Pushed, result.Pulled)
+	}
+	if _, err := laptop.Sync(filepath.Join(dir, "wago.json"), noConflicts); err == nil {
+		t.Error("syncing the ledger with itself succeeded")
+	}
+	check(laptop.Close())
+}
+
+// entityBytes converts a test table of entities to their stored form
+func entityBytes(entities map[entityKey]string) map[entityKey][]byte {
+	out := make(map[entityKey][]byte, len(entities))
+	for k, v := range entities {
+		out[k] = []byte(v)
+	}
+	return out
+}
+
+// entityStrings is the inverse of entityBytes
+func entityStrings(entities map[entityKey][]byte) map[entityKey]string {
+	out := make(map[entityKey]string, len(entities))
+	for k, v := range entities {
+		out[k] = string(v)
+	}
+	return out
+}
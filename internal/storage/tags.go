@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// NormalizeTags lowercases tags and strips a leading #, dropping empty and
+// repeated ones. Tags can't contain whitespace or commas.
+func NormalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if strings.ContainsAny(tag, " \t\n,#") {
+			return nil, fmt.Errorf("invalid tag '%s': tags can't contain spaces, commas or #", tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// HasTags reports whether tx carries every one of tags
+func HasTags(tx *model.Tx, tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, tag := range tx.Tags {
+			if tag == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
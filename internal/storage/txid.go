@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+// ErrTxExists is returned when adding a transaction whose ID is taken
+var ErrTxExists = errors.New("already exists")
+
+// ContentTxID derives a transaction ID from what the transaction records:
+// its type, wallets, coins, amounts, fee, date and on-chain hash. Adding the
+// same transaction twice then yields the same ID, so imports and scripts can
+// be rerun safely. Notes and addresses don't take part.
+func ContentTxID(tx *model.Tx) string {
+	fields := []string{
+		string(tx.Type),
+		tx.FromWallet,
+		tx.ToWallet,
+		tx.SwapWallet,
+		strings.ToUpper(tx.Coin),
+		tx.Amount.String(),
+		strings.ToUpper(tx.SellCoin),
+		tx.SellAmount.String(),
+		strings.ToUpper(tx.BuyCoin),
+		tx.BuyAmount.String(),
+		tx.Date.UTC().Format(time.RFC3339Nano),
+		strings.ToLower(tx.Hash),
+	}
+	// Appended only for bridges, so IDs of other types stay as they were
+	if tx.Type == model.TxTypeBridge {
+		fields = append(fields,
+			strings.ToLower(tx.FromChain),
+			strings.ToLower(tx.ToChain),
+			strings.ToUpper(tx.ReceivedCoin),
+			tx.ReceivedAmount.String())
+	}
+	if tx.Type == model.TxTypeLPAdd || tx.Type == model.TxTypeLPRemove {
+		fields = append(fields,
+			tx.Pool,
+			strings.ToUpper(tx.PairCoin),
+			tx.PairAmount.String(),
+			tx.LPTokens.String())
+	}
+	// Appended only when a fee is set, so fee-less IDs stay as they were
+	if !tx.Fee.IsZero() || tx.FeeCoin != "" {
+		fields = append(fields, tx.Fee.String(), strings.ToUpper(tx.FeeCoin))
+	}
+	for _, leg := range tx.Legs {
+		fields = append(fields, leg.Wallet, strings.ToUpper(leg.Coin), leg.Amount.String())
+	}
+	canonical, _ := json.Marshal(fields)
+	sum := sha256.Sum256(canonical)
+	return "tx_" + hex.EncodeToString(sum[:8])
+}
+
+// scheduleTxID derives the ID of a schedule's occurrence from the schedule
+// name and the occurrence date, so schedules sharing a template don't
+// collide while rerunning one still yields the same IDs
+func scheduleTxID(name string, date time.Time) string {
+	canonical, _ := json.Marshal([]string{"schedule", name, date.UTC().Format(time.RFC3339Nano)})
+	sum := sha256.Sum256(canonical)
+	return "tx_" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vasylcode/wago/internal/model"
+)
+
+func TestContentTxID(t *testing.T) {
+	base := func() *model.Tx {
+		return &model.Tx{
+			Type:       model.TxTypeTransfer,
+			FromWallet: "a",
+			ToWallet:   "b",
+			Coin:       "ETH",
+			Amount:     dec("1.5"),
+			Date:       time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC),
+			Hash:       "0xABC",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(tx *model.Tx)
+		same   bool
+	}{
+		{"identical", func(tx *model.Tx) {}, true},
+		{"note", func(tx *model.Tx) { tx.Note = "rent" }, true},
+		{"addresses", func(tx *model.Tx) { tx.FromAddress, tx.ToAddress = "0x1", "0x2" }, true},
+		{"tags", func(tx *model.Tx) { tx.Tags = []string{"grant-x"} }, true},
+		{"coin case", func(tx *model.Tx) { tx.Coin = "eth" }, true},
+		{"hash case", func(tx *model.Tx) { tx.Hash = "0xabc" }, true},
+		{"same instant in another zone", func(tx *model.Tx) { tx.Date = tx.Date.In(time.FixedZone("EET", 2*3600)) }, true},
+		{"trailing zeros", func(tx *model.Tx) { tx.Amount = dec("1.50") }, true},
+		{"amount", func(tx *model.Tx) { tx.Amount = dec("1.6") }, false},
+		{"coin", func(tx *model.Tx) { tx.Coin = "SOL" }, false},
+		{"type", func(tx *model.Tx) { tx.Type = model.TxTypeBridge }, false},
+		{"sender", func(tx *model.Tx) { tx.FromWallet = "c" }, false},
+		{"receiver", func(tx *model.Tx) { tx.ToWallet = "c" }, false},
+		{"date", func(tx *model.Tx) { tx.Date = tx.Date.Add(time.Second) }, false},
+		{"hash", func(tx *model.Tx) { tx.Hash = "0xdef" }, false},
+		{"fee", func(tx *model.Tx) { tx.Fee = dec("0.001") }, false},
+		{"fee coin", func(tx *model.Tx) { tx.Fee, tx.FeeCoin = dec("0.001"), "USDC" }, false},
+		{"legs", func(tx *model.Tx) { tx.Legs = []model.Leg{{Wallet: "a", Coin: "ETH", Amount: dec("-1")}} }, false},
+	}
+
+	want := ContentTxID(base())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := base()
+			tt.change(tx)
+			got := ContentTxID(tx)
+			if (got == want) != tt.same {
+				t.Errorf("ContentTxID() = %s, base %s, want same=%v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func TestContentTxIDFee(t *testing.T) {
+	tx := &model.Tx{Type: model.TxTypeDeposit, ToWallet: "a", Coin: "ETH", Amount: dec("1"), Date: day("2024-01-01"), Fee: dec("0.1"), FeeCoin: "ETH"}
+	other := *tx
+	other.FeeCoin = "USDC"
+	if ContentTxID(tx) == ContentTxID(&other) {
+		t.Errorf("fees in different coins share ID %s", ContentTxID(tx))
+	}
+}
+
+func TestContentTxIDStable(t *testing.T) {
+	// IDs are stored in ledgers and matched on import, so they must not
+	// change between releases
+	tx := &model.Tx{Type: model.TxTypeDeposit, ToWallet: "a", Coin: "ETH", Amount: dec("1"), Date: day("2024-01-01")}
+	if got, want := ContentTxID(tx), "tx_262aca23b724aa12"; got != want {
+		t.Errorf("ContentTxID() = %s, want %s", got, want)
+	}
+}
+
+func TestScheduleTxID(t *testing.T) {
+	date := day("2024-05-01")
+	if scheduleTxID("dca", date) != scheduleTxID("dca", date) {
+		t.Error("scheduleTxID() is not deterministic")
+	}
+	if scheduleTxID("dca", date) == scheduleTxID("salary", date) {
+		t.Error("schedules sharing a date share an ID")
+	}
+	if scheduleTxID("dca", date) == scheduleTxID("dca", date.AddDate(0, 1, 0)) {
+		t.Error("occurrences of a schedule share an ID")
+	}
+}
@@ -0,0 +1,21 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// ParseAmount parses an amount as typed by the user, keeping every digit
+func ParseAmount(s string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount: %s", s)
+	}
+	return amount, nil
+}
+
+// USDValue returns the USD value of amount at price
+func USDValue(amount decimal.Decimal, price float64) decimal.Decimal {
+	return amount.Mul(decimal.NewFromFloat(price))
+}
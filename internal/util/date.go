@@ -0,0 +1,78 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// relativeDatePattern matches offsets like -3d, -2w or -6h
+var relativeDatePattern = regexp.MustCompile(`^([+-])(\d+)([hdw])$`)
+
+// dateLayouts are the absolute forms ParseDate accepts, tried in order
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseDate parses a date as typed by the user:
+//   - now, today, yesterday
+//   - offsets from now: -3d, -2w, -6h (days and weeks count from midnight)
+//   - ISO dates and datetimes: 2024-03-01, 2024-03-01T14:30, 2024-03-01 14:30:00
+//   - RFC 3339 with an offset: 2024-03-01T14:30:00+02:00
+//
+// Anything but RFC 3339 is read in local time unless a zone is appended in
+// brackets, e.g. 2024-03-01T14:30[Europe/Kyiv] or yesterday[UTC].
+func ParseDate(s string) (time.Time, error) {
+	value := strings.TrimSpace(s)
+	loc := time.Local
+	if i := strings.LastIndex(value, "["); i >= 0 && strings.HasSuffix(value, "]") {
+		zone, err := time.LoadLocation(value[i+1 : len(value)-1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time zone in date: %s", s)
+		}
+		value, loc = value[:i], zone
+	}
+
+	now := time.Now().In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch strings.ToLower(value) {
+	case "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+
+	if m := relativeDatePattern.FindStringSubmatch(value); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s", s)
+		}
+		if m[1] == "-" {
+			n = -n
+		}
+		switch m[3] {
+		case "h":
+			return now.Add(time.Duration(n) * time.Hour), nil
+		case "d":
+			return midnight.AddDate(0, 0, n), nil
+		case "w":
+			return midnight.AddDate(0, 0, 7*n), nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", s)
+}
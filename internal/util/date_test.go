@@ -0,0 +1,80 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		approx  bool // Relative to the clock, compared to the minute
+		wantErr string
+	}{
+		{input: "2024-03-01[UTC]", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2024-03-01T14:30[UTC]", want: time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)},
+		{input: "2024-03-01T14:30:15[UTC]", want: time.Date(2024, 3, 1, 14, 30, 15, 0, time.UTC)},
+		{input: "2024-03-01 14:30[UTC]", want: time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)},
+		{input: "2024-03-01 14:30:15[UTC]", want: time.Date(2024, 3, 1, 14, 30, 15, 0, time.UTC)},
+		{input: "  2024-03-01[UTC]  ", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "2024-03-01T14:30[Europe/Kyiv]", want: time.Date(2024, 3, 1, 14, 30, 0, 0, kyiv)},
+		{input: "2024-03-01T14:30:00+02:00", want: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{input: "2024-03-01T14:30:00.5Z", want: time.Date(2024, 3, 1, 14, 30, 0, 5e8, time.UTC)},
+		{input: "today[UTC]", want: midnight},
+		{input: "Yesterday[UTC]", want: midnight.AddDate(0, 0, -1)},
+		{input: "-3d[UTC]", want: midnight.AddDate(0, 0, -3)},
+		{input: "+1d[UTC]", want: midnight.AddDate(0, 0, 1)},
+		{input: "-2w[UTC]", want: midnight.AddDate(0, 0, -14)},
+		{input: "now[UTC]", want: now, approx: true},
+		{input: "-6h[UTC]", want: now.Add(-6 * time.Hour), approx: true},
+		{input: "2024-13-01", wantErr: "invalid date"},
+		{input: "03/01/2024", wantErr: "invalid date"},
+		{input: "-3m", wantErr: "invalid date"},
+		{input: "", wantErr: "invalid date"},
+		{input: "today[Mars/Olympus]", wantErr: "invalid time zone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDate(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseDate(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q) error = %v", tt.input, err)
+			}
+			if tt.approx {
+				if diff := got.Sub(tt.want); diff < -time.Minute || diff > time.Minute {
+					t.Errorf("ParseDate(%q) = %v, want about %v", tt.input, got, tt.want)
+				}
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateLocal(t *testing.T) {
+	// Without a zone, dates are read in local time
+	got, err := ParseDate("2024-03-01T14:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 1, 14, 30, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("ParseDate() = %v, want %v", got, want)
+	}
+}
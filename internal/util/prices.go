@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/vasylcode/wago/internal/model"
 	"github.com/vasylcode/wago/internal/storage"
 	"github.com/vasylcode/wago/internal/version"
@@ -21,6 +22,7 @@ func GetCoinPrices(coinSymbols []string) (map[string]float64, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load storage: %w", err)
 	}
+	defer s.Close()
 
 	allPrices := s.GetPrices()
 
@@ -37,7 +39,7 @@ func GetCoinPrices(coinSymbols []string) (map[string]float64, error) {
 }
 
 // UpdateCoinPrices fetches current USD prices and stores them in wago.json.
-func UpdateCoinPrices(s *storage.Storage, wallets []*model.Wallet) error {
+func UpdateCoinPrices(s storage.Store, wallets []*model.Wallet) error {
 	coins := make(map[string]bool)
 	for coin := range s.GetPrices() {
 		coin = strings.ToLower(strings.TrimSpace(coin))
@@ -106,12 +108,12 @@ func UpdateCoinPrices(s *storage.Storage, wallets []*model.Wallet) error {
 }
 
 // FormatUSDValue formats a USD value for display
-func FormatUSDValue(value float64) string {
-	if value >= 1000000 {
-		return fmt.Sprintf("$%.2fM", value/1000000)
-	} else if value >= 1000 {
-		return fmt.Sprintf("$%.2fK", value/1000)
+func FormatUSDValue(value decimal.Decimal) string {
+	if value.GreaterThanOrEqual(decimal.NewFromInt(1000000)) {
+		return fmt.Sprintf("$%sM", value.Div(decimal.NewFromInt(1000000)).StringFixed(2))
+	} else if value.GreaterThanOrEqual(decimal.NewFromInt(1000)) {
+		return fmt.Sprintf("$%sK", value.Div(decimal.NewFromInt(1000)).StringFixed(2))
 	} else {
-		return fmt.Sprintf("$%.2f", value)
+		return fmt.Sprintf("$%s", value.StringFixed(2))
 	}
 }